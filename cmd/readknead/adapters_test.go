@@ -0,0 +1,83 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/operations"
+	"git.sr.ht/~vejnar/ReadKnead/lib/param"
+)
+
+func TestPrintAdapters(t *testing.T) {
+	var b bytes.Buffer
+	printAdapters(&b)
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 11 {
+		t.Fatalf("got %d lines, expected 11", len(lines))
+	}
+	if fields := strings.Fields(lines[0]); strings.Join(fields, " ") != "name end sequence sequence_paired description" {
+		t.Errorf("wrong header: %s", lines[0])
+	}
+	if fields := strings.Fields(lines[5]); fields[0] != "nextera" || fields[1] != "3" || fields[2] != "CTGTCTCTTATACACATCT" || fields[3] != "CTGTCTCTTATACACATCT" {
+		t.Errorf("wrong nextera line: %s", lines[5])
+	}
+}
+
+func TestPresetSequence(t *testing.T) {
+	param := param.Parameters{AsciiMin: 33, MaxQual: 43}
+	for _, test := range []struct {
+		ops string
+		err string
+	}{
+		{`[{"name": "trim", "end": 3, "sequence": "nextera"}]`, ""},
+		{`[{"name": "trim", "end": 3, "algo": "search", "sequence": "ACGTNNRY"}]`, ""},
+		{`[{"name": "trim", "end": 3, "sequence": "nextra"}]`, "unknown adapter (or not a sequence): nextra"},
+		{`[{"name": "trim", "end": 3, "sequences": ["truseq_r1", "ACGT-"]}]`, "unknown adapter (or not a sequence): ACGT-"},
+		{`[{"name": "trim", "end": 3, "sequence": "ACGT", "sequence_paired": "truseq_r3"}]`, "unknown adapter (or not a sequence): truseq_r3"},
+	} {
+		_, err := operations.ReadOps([]byte(test.ops), param)
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("%s: got error %v, expected %q", test.ops, err, test.err)
+		}
+	}
+}
+
+func TestPresetEnd(t *testing.T) {
+	param := param.Parameters{AsciiMin: 33, MaxQual: 43}
+	for _, test := range []struct {
+		ops string
+		end int
+		err string
+	}{
+		{`[{"name": "trim", "algo": "search", "sequence": "smarter_tso"}]`, 5, ""},
+		{`[{"name": "trim", "algo": "search", "sequence": "10x_tso", "end": 3}]`, 3, ""},
+		{`[{"name": "trim", "algo": "search", "sequence": "truseq_r1"}]`, 3, ""},
+		{`[{"name": "trim", "algo": "search", "sequences": ["ACGTACGT", "10x_tso"]}]`, 5, ""},
+		{`[{"name": "trim", "algo": "search", "mode": "anywhere", "sequence": "10x_tso"}]`, 5, ""},
+		{`[{"name": "trim", "algo": "search", "sequence": "ACGTACGT"}]`, 0, "Key path not found: end"},
+		{`[{"name": "trim", "algo": "search", "sequences": ["truseq_r1", "10x_tso"]}]`, 0, "Key path not found: end"},
+	} {
+		ops, err := operations.ReadOps([]byte(test.ops), param)
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("%s: got error %v, expected %q", test.ops, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		for _, p := range operations.Describe(ops[0]) {
+			if p.Key == "end" && p.Value != test.end {
+				t.Errorf("%s: got end %v, expected %d", test.ops, p.Value, test.end)
+			}
+		}
+	}
+}
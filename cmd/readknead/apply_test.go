@@ -24,9 +24,14 @@ type TestSet struct {
 	fastqsR2     string
 	fqFnameOutR1 string
 	fqFnameOutR2 string
+	fqFnameOutS1 string
+	fqFnameOutS2 string
+	fqFnameOutX1 string
+	fqFnameOutX2 string
 	opsR1Path    string
 	opsR2Path    string
 	goldenPath   string
+	nPair        uint64
 }
 
 func TestApplyOperations(t *testing.T) {
@@ -57,6 +62,30 @@ func TestApplyOperations(t *testing.T) {
 			opsR1Path:    "demultiplex.json",
 			goldenPath:   "sample2_demultiplex_*_R*.fastq.golden",
 		},
+		{
+			fastqsR1:     "sample2_R1.fastq",
+			fastqsR2:     "sample2_R2.fastq",
+			fqFnameOutR1: "sample2_rename_[DPX]_R1.fastq",
+			fqFnameOutR2: "sample2_rename_[DPX]_R2.fastq",
+			opsR1Path:    "rename_template.json",
+			goldenPath:   "sample2_rename_*_R*.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample11_R1.fastq",
+			fastqsR2:     "",
+			fqFnameOutR1: "sample11_demultiplex_[DPX]_R1.fastq",
+			fqFnameOutR2: "",
+			opsR1Path:    "demultiplex_header.json",
+			goldenPath:   "sample11_demultiplex_*_R1.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample2_R1.fastq",
+			fastqsR2:     "sample2_R2.fastq",
+			fqFnameOutR1: "sample2_pipelines_[DPX]_R1.fastq",
+			fqFnameOutR2: "sample2_pipelines_[DPX]_R2.fastq",
+			opsR1Path:    "demultiplex_pipelines.json",
+			goldenPath:   "sample2_pipelines_*_R*.fastq.golden",
+		},
 		{
 			fastqsR1:     "sample2_R1.fastq",
 			fastqsR2:     "sample2_R2.fastq",
@@ -66,6 +95,72 @@ func TestApplyOperations(t *testing.T) {
 			opsR2Path:    "filter_quality.json",
 			goldenPath:   "sample2_filter_quality_R*.fastq.golden",
 		},
+		{
+			fastqsR1:     "sample2_R1.fastq",
+			fastqsR2:     "sample2_R2.fastq",
+			fqFnameOutR1: "sample2_filter_quality_mate_R1.fastq",
+			fqFnameOutR2: "sample2_filter_quality_mate_R2.fastq",
+			fqFnameOutS1: "sample2_filter_quality_mate_single_R1.fastq",
+			fqFnameOutS2: "sample2_filter_quality_mate_single_R2.fastq",
+			opsR1Path:    "filter_quality_mate.json",
+			opsR2Path:    "filter_quality_mate.json",
+			goldenPath:   "sample2_filter_quality_mate_*R*.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample2_R1.fastq",
+			fastqsR2:     "sample2_R2.fastq",
+			fqFnameOutR1: "sample2_filter_quality_mate_R1.fastq",
+			fqFnameOutR2: "sample2_filter_quality_mate_R2.fastq",
+			fqFnameOutX1: "sample2_rejected_quality_mate_R1.fastq",
+			fqFnameOutX2: "sample2_rejected_quality_mate_R2.fastq",
+			opsR1Path:    "filter_quality_mate.json",
+			opsR2Path:    "filter_quality_mate.json",
+			goldenPath:   "sample2_rejected_quality_mate_R*.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample2_R1.fastq",
+			fastqsR2:     "sample2_R2.fastq",
+			fqFnameOutR1: "sample2_mate_rename_R1.fastq",
+			fqFnameOutR2: "sample2_mate_rename_R2.fastq",
+			fqFnameOutS1: "sample2_mate_rename_single_R1.fastq",
+			fqFnameOutS2: "sample2_mate_rename_single_R2.fastq",
+			opsR1Path:    "filter_quality_mate.json",
+			opsR2Path:    "rename_mate.json",
+			goldenPath:   "sample2_mate_rename_*R*.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample2_R1.fastq",
+			fastqsR2:     "sample2_R2.fastq",
+			fqFnameOutR1: "sample2_filter_quality_ee_R1.fastq",
+			fqFnameOutR2: "sample2_filter_quality_ee_R2.fastq",
+			opsR1Path:    "filter_quality_ee.json",
+			goldenPath:   "sample2_filter_quality_ee_R*.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample2_R1.fastq",
+			fastqsR2:     "sample2_R2.fastq",
+			fqFnameOutR1: "sample2_random_R1.fastq",
+			fqFnameOutR2: "sample2_random_R2.fastq",
+			opsR1Path:    "random_seed.json",
+			goldenPath:   "sample2_random_R*.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample2_R1.fastq",
+			fastqsR2:     "sample2_R2.fastq",
+			fqFnameOutR1: "sample2_random_count_R1.fastq",
+			fqFnameOutR2: "sample2_random_count_R2.fastq",
+			opsR1Path:    "random_count.json",
+			goldenPath:   "sample2_random_count_R*.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample2_R1.fastq",
+			fastqsR2:     "sample2_R2.fastq",
+			fqFnameOutR1: "sample2_random_count_mate_R1.fastq",
+			fqFnameOutR2: "sample2_random_count_mate_R2.fastq",
+			opsR1Path:    "random_seed.json",
+			opsR2Path:    "random_count_mate.json",
+			goldenPath:   "sample2_random_count_mate_R*.fastq.golden",
+		},
 		{
 			fastqsR1:     "sample3_R1.fastq",
 			fastqsR2:     "",
@@ -90,6 +185,120 @@ func TestApplyOperations(t *testing.T) {
 			opsR1Path:    "trim_length.json",
 			goldenPath:   "sample4_R1.fastq.golden",
 		},
+		{
+			fastqsR1:     "sample4_R1.fastq",
+			fastqsR2:     "",
+			fqFnameOutR1: "sample4_R1.fastq",
+			fqFnameOutR2: "",
+			fqFnameOutX1: "sample4_rejected_R1.fastq",
+			opsR1Path:    "trim_length.json",
+			goldenPath:   "sample4_rejected_R1.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample4_R1.fastq",
+			fastqsR2:     "",
+			fqFnameOutR1: "sample4_rename_R1.fastq",
+			fqFnameOutR2: "",
+			opsR1Path:    "rename_first.json",
+			goldenPath:   "sample4_rename_R1.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample4_R1.fastq",
+			fastqsR2:     "",
+			fqFnameOutR1: "sample4_screen_R1.fastq",
+			fqFnameOutR2: "",
+			opsR1Path:    "screen.json",
+			goldenPath:   "sample4_screen_R1.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample5_R1.fastq",
+			fastqsR2:     "",
+			fqFnameOutR1: "sample5_R1.fastq",
+			fqFnameOutR2: "",
+			opsR1Path:    "complexity.json",
+			goldenPath:   "sample5_R1.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample6_R1.fastq",
+			fastqsR2:     "",
+			fqFnameOutR1: "sample6_trim_linked_R1.fastq",
+			fqFnameOutR2: "",
+			opsR1Path:    "trim_linked.json",
+			goldenPath:   "sample6_trim_linked_R1.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample6_R1.fastq",
+			fastqsR2:     "",
+			fqFnameOutR1: "sample6_trim_anywhere_R1.fastq",
+			fqFnameOutR2: "",
+			opsR1Path:    "trim_anywhere.json",
+			goldenPath:   "sample6_trim_anywhere_R1.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample6_R1.fastq",
+			fastqsR2:     "",
+			fqFnameOutR1: "sample6_trim_align_R1.fastq",
+			fqFnameOutR2: "",
+			opsR1Path:    "trim_align.json",
+			goldenPath:   "sample6_trim_align_R1.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample6_R1.fastq",
+			fastqsR2:     "",
+			fqFnameOutR1: "sample6_trim_action_R1.fastq",
+			fqFnameOutR2: "",
+			opsR1Path:    "trim_action.json",
+			goldenPath:   "sample6_trim_action_R1.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample6_R1.fastq",
+			fastqsR2:     "",
+			fqFnameOutR1: "sample6_trim_if_R1.fastq",
+			fqFnameOutR2: "",
+			opsR1Path:    "trim_if.json",
+			goldenPath:   "sample6_trim_if_R1.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample7_R1.fastq",
+			fastqsR2:     "",
+			fqFnameOutR1: "sample7_R1.fastq",
+			fqFnameOutR2: "",
+			opsR1Path:    "trim_iupac.json",
+			goldenPath:   "sample7_R1.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample8_R1.fastq",
+			fastqsR2:     "",
+			fqFnameOutR1: "sample8_R1.fastq",
+			fqFnameOutR2: "",
+			opsR1Path:    "trim_times.json",
+			goldenPath:   "sample8_R1.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample12_R1.fastq",
+			fastqsR2:     "",
+			fqFnameOutR1: "sample12_R1.fastq",
+			fqFnameOutR2: "",
+			opsR1Path:    "trim_auto.json",
+			goldenPath:   "sample12_R1.fastq.golden",
+			nPair:        40,
+		},
+		{
+			fastqsR1:     "sample9_R1.fastq",
+			fastqsR2:     "sample9_R2.fastq",
+			fqFnameOutR1: "sample9_amplicon_[DPX]_R1.fastq",
+			fqFnameOutR2: "sample9_amplicon_[DPX]_R2.fastq",
+			opsR1Path:    "amplicon.json",
+			goldenPath:   "sample9_amplicon_*_R*.fastq.golden",
+		},
+		{
+			fastqsR1:     "sample10_R1.fastq",
+			fastqsR2:     "sample10_R2.fastq",
+			fqFnameOutR1: "sample10_R1.fastq",
+			fqFnameOutR2: "sample10_R2.fastq",
+			opsR1Path:    "orient.json",
+			goldenPath:   "sample10_R*.fastq.golden",
+		},
 	}
 
 	for _, test := range tests {
@@ -110,7 +319,7 @@ func TestApplyOperations(t *testing.T) {
 		nWorker := 1
 		verboseLevel := 10
 
-		param := param.Parameters{AsciiMin: 33, MaxQual: 43, Paired: false}
+		param := param.Parameters{AsciiMin: 33, MaxQual: 43, Paired: false, FastqsR1: fastqsR1, FastqsR2: fastqsR2, FqCmdIn: fqCmdIn, BufSize: bufSize, Dir: "testdata"}
 		if len(fastqsR2) > 0 {
 			param.Paired = true
 		}
@@ -130,12 +339,15 @@ func TestApplyOperations(t *testing.T) {
 		}
 
 		// Run
-		nPair, err := ApplyOperations(fastqsR1, fastqsR2, fqPathOut, test.fqFnameOutR1, test.fqFnameOutR2, fqCmdIn, fqCmdOut, opsR1, opsR2, param, statsInPath, statsOutPath, maxReadLength, reportPath, label, bufSize, nWorker, verboseLevel)
+		nPair, err := ApplyOperations(fastqsR1, fastqsR2, fqPathOut, test.fqFnameOutR1, test.fqFnameOutR2, test.fqFnameOutS1, test.fqFnameOutS2, test.fqFnameOutX1, test.fqFnameOutX2, fqCmdIn, fqCmdOut, opsR1, opsR2, param, statsInPath, statsOutPath, maxReadLength, reportPath, label, bufSize, nWorker, verboseLevel)
 		if err != nil {
 			t.Fatalf("apply failed: %s", err)
 		}
-		if nPair != 4 {
-			t.Error("apply returned", nPair, "expected 4")
+		if test.nPair == 0 {
+			test.nPair = 4
+		}
+		if nPair != test.nPair {
+			t.Error("apply returned", nPair, "expected", test.nPair)
 		}
 
 		// Wait for the output file(s) to be available
@@ -164,3 +376,64 @@ func TestApplyOperations(t *testing.T) {
 		}
 	}
 }
+
+func TestCountingOps(t *testing.T) {
+	param := param.Parameters{AsciiMin: 33, MaxQual: 43}
+	for _, test := range []struct {
+		ops string
+		err string
+	}{
+		{`[{"name": "random", "probability": 0.5, "seed": 1}, {"name": "random", "label": "sample", "count": 2}]`, ""},
+		{`[{"name": "random", "probability": 0.5}, {"name": "random", "label": "sample", "count": 2}]`, "random: non-reproducible operation before sampling (count) operation"},
+		{`[{"name": "random", "label": "sample", "count": 2}, {"name": "random", "probability": 0.5}]`, ""},
+	} {
+		ops, err := operations.ReadOps([]byte(test.ops), param)
+		if err != nil {
+			t.Fatalf("failed reading json: %s", err)
+		}
+		_, _, err = countingOps(pipelineOps(ops, nil))
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("%s: got error %v, expected %q", test.ops, err, test.err)
+		}
+	}
+}
+
+func TestGetDpxNames(t *testing.T) {
+	param := param.Parameters{AsciiMin: 33, MaxQual: 43, Paired: true}
+	for _, test := range []struct {
+		ops   string
+		names string
+		ids   func(operations.Operation) []int
+	}{
+		{
+			`[{"name": "screen", "k": 21, "demultiplex": true, "references": [{"name": "phix", "path": "testdata/screen_phix.fa"}, {"name": "rrna", "path": "testdata/screen_rrna.fa"}]}]`,
+			"clean,phix,rrna",
+			func(op operations.Operation) []int { return op.(*operations.Screen).ReferencesID },
+		},
+		{
+			`[{"name": "amplicon", "demultiplex": true, "primers": [{"name": "ampA", "forward": "ACGTACGT", "reverse": "TTGGCCAA"}, {"name": "ampB", "forward": "GGGGCCCC", "reverse": "AAAATTTT"}]}]`,
+			"undetermined,ampA,ampB",
+			func(op operations.Operation) []int { return op.(*operations.Amplicon).AmpliconsID },
+		},
+		{
+			`[{"name": "demultiplex", "end": 5, "barcodes": ["GAGTA", "CTGAG"]}]`,
+			"undetermined,GAGTA,CTGAG",
+			func(op operations.Operation) []int { return op.(*operations.Demultiplex).BarcodesID },
+		},
+	} {
+		ops, err := operations.ReadOps([]byte(test.ops), param)
+		if err != nil {
+			t.Fatalf("failed reading json: %s", err)
+		}
+		// Called by validate then apply
+		for i := 0; i < 2; i++ {
+			names, _ := getDpxNames(ops, nil)
+			if string(bytes.Join(names, []byte(","))) != test.names {
+				t.Errorf("got names %s, expected %s", bytes.Join(names, []byte(",")), test.names)
+			}
+		}
+		if ids := test.ids(ops[0]); len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+			t.Errorf("%s: got sample IDs %v, expected [1 2]", ops[0].Name(), ids)
+		}
+	}
+}
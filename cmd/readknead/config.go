@@ -0,0 +1,131 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buger/jsonparser"
+)
+
+// Flags not available in project file
+var configExcluded = map[string]bool{"config": true, "version": true, "list_adapters": true}
+
+// Flags overriding each other
+var configGroups = map[string]string{"ops_r1": "ops_r1", "ops_r1_path": "ops_r1", "ops_r2": "ops_r2", "ops_r2_path": "ops_r2"}
+
+// Paths relative to project file
+var configRelPaths = map[string]bool{"fq_fnames_r1": true, "fq_fnames_r2": true, "fq_path_out": true, "report_path": true, "stats_in_path": true, "stats_out_path": true, "ops_r1_path": true, "ops_r2_path": true}
+
+// Output file names relative to fq_path_out, or to project file if fq_path_out isn't set
+var configOutPaths = map[string]bool{"fq_fname_out_r1": true, "fq_fname_out_r2": true, "fq_fname_out_single_r1": true, "fq_fname_out_single_r2": true, "fq_fname_out_rejected_r1": true, "fq_fname_out_rejected_r2": true}
+
+// Set flags of fs from JSON project file at path. Keys are flag names; flags set in the command
+// line are kept. Lists (input files, commands) are arrays and operations (ops_r1, ops_r2) are
+// arrays of operations. Relative paths are relative to the project file.
+func applyConfig(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// Flags set in the command line
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+		if g, ok := configGroups[f.Name]; ok {
+			set[g] = true
+		}
+	})
+	dir := filepath.Dir(path)
+	var outNames []string
+	err = jsonparser.ObjectEach(data, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
+		name := string(key)
+		if fs.Lookup(name) == nil || configExcluded[name] {
+			return fmt.Errorf("%s: unknown parameter %s", path, name)
+		}
+		if g, ok := configGroups[name]; set[name] || (ok && set[g]) {
+			return nil
+		}
+		var v string
+		switch dataType {
+		case jsonparser.Array:
+			if name == "ops_r1" || name == "ops_r2" {
+				v = string(value)
+				break
+			}
+			var items []string
+			var err error
+			jsonparser.ArrayEach(value, func(item []byte, itemType jsonparser.ValueType, offset int, err2 error) {
+				if err == nil {
+					if itemType != jsonparser.String {
+						err = fmt.Errorf("%s: %s: array of strings expected", path, name)
+						return
+					}
+					var s string
+					s, err = jsonparser.ParseString(item)
+					items = append(items, s)
+				}
+			})
+			if err != nil {
+				return err
+			}
+			v = strings.Join(items, ",")
+		case jsonparser.String:
+			var err error
+			v, err = jsonparser.ParseString(value)
+			if err != nil {
+				return err
+			}
+		case jsonparser.Number, jsonparser.Boolean:
+			v = string(value)
+		default:
+			return fmt.Errorf("%s: %s: wrong type", path, name)
+		}
+		if configRelPaths[name] {
+			v = relPaths(dir, v)
+		}
+		if configOutPaths[name] {
+			outNames = append(outNames, name)
+		}
+		if err := fs.Set(name, v); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Output files in project file directory
+	if fs.Lookup("fq_path_out").Value.String() == "" {
+		for _, name := range outNames {
+			if err := fs.Set(name, relPaths(dir, fs.Lookup(name).Value.String())); err != nil {
+				return fmt.Errorf("%s: %s: %w", path, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Join relative paths of comma separated list v to dir (- for stdout is kept)
+func relPaths(dir string, v string) string {
+	if v == "" {
+		return v
+	}
+	paths := strings.Split(v, ",")
+	for i, p := range paths {
+		if p != "" && p != "-" && !filepath.IsAbs(p) {
+			paths[i] = filepath.Join(dir, p)
+		}
+	}
+	return strings.Join(paths, ",")
+}
@@ -0,0 +1,167 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyConfig(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := filepath.Join(tmp, "project.json")
+	err := os.WriteFile(configPath, []byte(`{"label": "WT", "num_worker": 4, "fq_fnames_r1": ["a_R1.fastq", "b_R1.fastq"], "ops_r1": [{"name": "length", "min_length": 20}], "ops_r2_path": "ops_r2.json"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		args   []string
+		values map[string]string
+	}{
+		{
+			[]string{},
+			map[string]string{"label": "WT", "num_worker": "4", "fq_fnames_r1": filepath.Join(tmp, "a_R1.fastq") + "," + filepath.Join(tmp, "b_R1.fastq"), "ops_r1": `[{"name": "length", "min_length": 20}]`, "ops_r1_path": "", "ops_r2_path": filepath.Join(tmp, "ops_r2.json")},
+		},
+		{
+			// Command line set to default value
+			[]string{"-num_worker", "1", "-label", ""},
+			map[string]string{"label": "", "num_worker": "1"},
+		},
+		{
+			// Operations from command line override both ops_r1 and ops_r1_path
+			[]string{"-ops_r1_path", "cli.json", "-ops_r2", "[]"},
+			map[string]string{"ops_r1": "", "ops_r1_path": "cli.json", "ops_r2": "[]", "ops_r2_path": ""},
+		},
+	} {
+		fs := newConfigFlagSet()
+		if err := fs.Parse(test.args); err != nil {
+			t.Fatal(err)
+		}
+		if err := applyConfig(fs, configPath); err != nil {
+			t.Fatalf("%v: %s", test.args, err)
+		}
+		for name, value := range test.values {
+			if v := fs.Lookup(name).Value.String(); v != value {
+				t.Errorf("%v: %s is %q, expected %q", test.args, name, v, value)
+			}
+		}
+	}
+}
+
+func TestApplyConfigPaths(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmp, "project"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Project file given relative to another working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	configPath := filepath.Join("project", "project.json")
+	for _, test := range []struct {
+		config string
+		values map[string]string
+	}{
+		{
+			`{"fq_fnames_r1": ["a_R1.fastq", "/data/b_R1.fastq"], "fq_fnames_r2": "a_R2.fastq,b_R2.fastq", "fq_path_out": "output", "fq_fname_out_r1": "a_R1.fastq", "report_path": "report.json", "stats_in_path": "stats/in", "stats_out_path": "stats/out", "ops_r1_path": "ops.json"}`,
+			map[string]string{
+				"fq_fnames_r1":    "project/a_R1.fastq,/data/b_R1.fastq",
+				"fq_fnames_r2":    "project/a_R2.fastq,project/b_R2.fastq",
+				"fq_path_out":     "project/output",
+				"fq_fname_out_r1": "a_R1.fastq",
+				"report_path":     "project/report.json",
+				"stats_in_path":   "project/stats/in",
+				"stats_out_path":  "project/stats/out",
+				"ops_r1_path":     "project/ops.json",
+			},
+		},
+		{
+			// Output files in project file directory without fq_path_out
+			`{"fq_fname_out_r1": "a_R1.fastq", "fq_fname_out_single_r1": "/data/a_single_R1.fastq", "fq_fname_out_rejected_r1": "a_rejected_R1.fastq", "report_path": "-"}`,
+			map[string]string{
+				"fq_path_out":              "",
+				"fq_fname_out_r1":          "project/a_R1.fastq",
+				"fq_fname_out_single_r1":   "/data/a_single_R1.fastq",
+				"fq_fname_out_rejected_r1": "project/a_rejected_R1.fastq",
+				"report_path":              "-",
+			},
+		},
+	} {
+		if err := os.WriteFile(configPath, []byte(test.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fs := newConfigFlagSet()
+		if err := applyConfig(fs, configPath); err != nil {
+			t.Fatalf("%s: %s", test.config, err)
+		}
+		for name, value := range test.values {
+			if v := fs.Lookup(name).Value.String(); v != value {
+				t.Errorf("%s: %s is %q, expected %q", test.config, name, v, value)
+			}
+		}
+	}
+}
+
+func TestApplyConfigError(t *testing.T) {
+	tmp := t.TempDir()
+	err := applyConfig(newConfigFlagSet(), filepath.Join(tmp, "missing.json"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing file: got error %v", err)
+	}
+	configPath := filepath.Join(tmp, "project.json")
+	for _, test := range []struct {
+		config string
+		err    string
+	}{
+		{`{"lable": "WT"}`, configPath + ": unknown parameter lable"},
+		{`{"version": true}`, configPath + ": unknown parameter version"},
+		{`{"fq_fnames_r1": [1, 2]}`, configPath + ": fq_fnames_r1: array of strings expected"},
+		{`{"num_worker": "four"}`, configPath + `: num_worker: parse error`},
+	} {
+		if err := os.WriteFile(configPath, []byte(test.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := applyConfig(newConfigFlagSet(), configPath)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: got error %v, expected %q", test.config, err, test.err)
+		}
+	}
+}
+
+// Subset of command line flags
+func newConfigFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("readknead", flag.ContinueOnError)
+	fs.String("config", "", "")
+	fs.Bool("version", false, "")
+	fs.String("label", "", "")
+	fs.Int("num_worker", 1, "")
+	fs.String("fq_fnames_r1", "", "")
+	fs.String("fq_fnames_r2", "", "")
+	fs.String("fq_path_out", "", "")
+	fs.String("fq_fname_out_r1", "", "")
+	fs.String("fq_fname_out_single_r1", "", "")
+	fs.String("fq_fname_out_rejected_r1", "", "")
+	fs.String("report_path", "", "")
+	fs.String("stats_in_path", "", "")
+	fs.String("stats_out_path", "", "")
+	fs.String("ops_r1", "", "")
+	fs.String("ops_r1_path", "", "")
+	fs.String("ops_r2", "", "")
+	fs.String("ops_r2_path", "", "")
+	return fs
+}
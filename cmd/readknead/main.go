@@ -11,14 +11,18 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"git.sr.ht/~vejnar/ReadKnead/lib/operations"
 	"git.sr.ht/~vejnar/ReadKnead/lib/param"
+	"git.sr.ht/~vejnar/ReadKnead/lib/trim"
 )
 
 var version = "DEV"
@@ -36,11 +40,22 @@ func readAll(path string) []byte {
 	return txt
 }
 
+// Print adapter catalog
+func printAdapters(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "name\tend\tsequence\tsequence_paired\tdescription")
+	for _, p := range trim.Presets {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", p.Name, p.End, p.Sequence, p.SequencePaired, p.Description)
+	}
+	w.Flush()
+}
+
 func main() {
 	// Arguments: General
-	var verbose, printVersion bool
-	var reportPath, label string
+	var verbose, printVersion, listAdapters bool
+	var configPath, reportPath, label string
 	var bufSize, nWorker, verboseLevel int
+	flag.StringVar(&configPath, "config", "", "Path to project file (JSON) with arguments; arguments in command line override it")
 	flag.StringVar(&reportPath, "report_path", "", "Write report to path (stdout with -)")
 	flag.StringVar(&label, "label", "", "Label")
 	flag.IntVar(&bufSize, "buf_size", 41943040, "Buffer IO size")
@@ -48,13 +63,18 @@ func main() {
 	flag.IntVar(&verboseLevel, "verbose_level", 0, "Verbose level")
 	flag.BoolVar(&verbose, "verbose", false, "Verbose")
 	flag.BoolVar(&printVersion, "version", false, "Print version and quit")
+	flag.BoolVar(&listAdapters, "list_adapters", false, "Print adapter catalog and quit")
 	// Arguments: FastQ
-	var fqFnamesR1, fqFnamesR2, fqPathOut, fqFnameOutR1, fqFnameOutR2, fqCmdInRaw, fqCmdOutRaw string
+	var fqFnamesR1, fqFnamesR2, fqPathOut, fqFnameOutR1, fqFnameOutR2, fqFnameOutSingleR1, fqFnameOutSingleR2, fqFnameOutRejectedR1, fqFnameOutRejectedR2, fqCmdInRaw, fqCmdOutRaw string
 	flag.StringVar(&fqFnamesR1, "fq_fnames_r1", "", "Path to read 1 FASTQ files (comma separated)")
 	flag.StringVar(&fqFnamesR2, "fq_fnames_r2", "", "Path to read 2 FASTQ files (comma separated)")
 	flag.StringVar(&fqPathOut, "fq_path_out", "", "Path to output FASTQ files")
 	flag.StringVar(&fqFnameOutR1, "fq_fname_out_r1", "", "Output read 1 FASTQ file")
 	flag.StringVar(&fqFnameOutR2, "fq_fname_out_r2", "", "Output read 2 FASTQ file")
+	flag.StringVar(&fqFnameOutSingleR1, "fq_fname_out_single_r1", "", "Output FASTQ file for read 1 without its mate")
+	flag.StringVar(&fqFnameOutSingleR2, "fq_fname_out_single_r2", "", "Output FASTQ file for read 2 without its mate")
+	flag.StringVar(&fqFnameOutRejectedR1, "fq_fname_out_rejected_r1", "", "Output read 1 FASTQ file for rejected reads")
+	flag.StringVar(&fqFnameOutRejectedR2, "fq_fname_out_rejected_r2", "", "Output read 2 FASTQ file for rejected reads")
 	flag.StringVar(&fqCmdInRaw, "fq_command_in", "", "Command line to execute for opening each input file (comma separated)")
 	flag.StringVar(&fqCmdOutRaw, "fq_command_out", "", "Command line to execute for opening each output file (comma separated)")
 	// Arguments: Stats
@@ -71,8 +91,22 @@ func main() {
 	flag.StringVar(&opsR2Raw, "ops_r2", "", "Operation(s) for read2")
 	flag.StringVar(&opsR1Path, "ops_r1_path", "", "Path to operation(s) for read1")
 	flag.StringVar(&opsR2Path, "ops_r2_path", "", "Path to operation(s) for read2")
-	// Arguments: Parsing
-	flag.Parse()
+	// Arguments: Parsing (with validate subcommand)
+	validate := len(os.Args) > 1 && os.Args[1] == "validate"
+	if validate {
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
+
+	// Project file
+	cmdSet := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { cmdSet[f.Name] = true })
+	if configPath != "" {
+		if err := applyConfig(flag.CommandLine, configPath); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// Version
 	if printVersion {
@@ -80,6 +114,12 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Adapter catalog
+	if listAdapters {
+		printAdapters(os.Stdout)
+		os.Exit(0)
+	}
+
 	// Verbose
 	if verbose && verboseLevel == 0 {
 		verboseLevel = 1
@@ -114,9 +154,6 @@ func main() {
 		}
 	}
 
-	// Shared parameters
-	param := param.Parameters{AsciiMin: asciiMin, MaxQual: maxQual, Paired: paired}
-
 	// Commands
 	var fqCmdIn, fqCmdOut []string
 	if len(fqCmdInRaw) > 0 {
@@ -126,37 +163,67 @@ func main() {
 		fqCmdOut = strings.Split(fqCmdOutRaw, ",")
 	}
 
+	// Shared parameters
+	param := param.Parameters{AsciiMin: asciiMin, MaxQual: maxQual, Paired: paired, FastqsR1: fastqsR1, FastqsR2: fastqsR2, FqCmdIn: fqCmdIn, BufSize: bufSize}
+	paramR1, paramR2 := param, param
+	paramR1.Read = 1
+	paramR2.Read = 2
+	// Relative paths in operations: to operations file, or to project file for operations in it
+	opsDir := func(name string, path string) string {
+		if path != "" {
+			return filepath.Dir(path)
+		}
+		if configPath != "" && !cmdSet[name] {
+			return filepath.Dir(configPath)
+		}
+		return ""
+	}
+	paramR1.Dir = opsDir("ops_r1", opsR1Path)
+	paramR2.Dir = opsDir("ops_r2", opsR2Path)
+
 	// Operations
 	var opsR1, opsR2 []operations.Operation
 	var err error
 	if opsR1Raw != "" {
-		opsR1, err = operations.ReadOps([]byte(opsR1Raw), param)
+		opsR1, err = operations.ReadOps([]byte(opsR1Raw), paramR1)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 	if opsR2Raw != "" {
-		opsR2, err = operations.ReadOps([]byte(opsR2Raw), param)
+		opsR2, err = operations.ReadOps([]byte(opsR2Raw), paramR2)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 	if opsR1Path != "" {
-		opsR1, err = operations.ReadOps(readAll(opsR1Path), param)
+		opsR1, err = operations.ReadOps(readAll(opsR1Path), paramR1)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 	if opsR2Path != "" {
-		opsR2, err = operations.ReadOps(readAll(opsR2Path), param)
+		opsR2, err = operations.ReadOps(readAll(opsR2Path), paramR2)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 
+	// Validate
+	if validate {
+		errs := validateRun(os.Stdout, fastqsR1, fastqsR2, fqCmdIn, bufSize, fqPathOut, fqFnameOutR1, fqFnameOutR2, fqFnameOutSingleR1, fqFnameOutSingleR2, fqFnameOutRejectedR1, fqFnameOutRejectedR2, opsR1, opsR2, paired)
+		for _, e := range errs {
+			log.Println(e)
+		}
+		if len(errs) > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Apply
 	var nPair uint64
-	nPair, err = ApplyOperations(fastqsR1, fastqsR2, fqPathOut, fqFnameOutR1, fqFnameOutR2, fqCmdIn, fqCmdOut, opsR1, opsR2, param, statsInPath, statsOutPath, maxReadLength, reportPath, label, bufSize, nWorker, verboseLevel)
+	nPair, err = ApplyOperations(fastqsR1, fastqsR2, fqPathOut, fqFnameOutR1, fqFnameOutR2, fqFnameOutSingleR1, fqFnameOutSingleR2, fqFnameOutRejectedR1, fqFnameOutRejectedR2, fqCmdIn, fqCmdOut, opsR1, opsR2, param, statsInPath, statsOutPath, maxReadLength, reportPath, label, bufSize, nWorker, verboseLevel)
 	if err != nil {
 		log.Fatal(err)
 	}
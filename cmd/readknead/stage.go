@@ -0,0 +1,300 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+	"git.sr.ht/~vejnar/ReadKnead/lib/operations"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Operation applied to read 1 or 2
+type stageOp struct {
+	op   operations.Operation
+	read int
+}
+
+// Stage of consecutive operations run either in parallel (thread-safe operations) or in order
+type stage struct {
+	ops        []stageOp
+	threadSafe bool
+}
+
+// Operations in declared order: read 1 then read 2
+func pipelineOps(opsR1 []operations.Operation, opsR2 []operations.Operation) []stageOp {
+	var sops []stageOp
+	for _, op := range opsR1 {
+		sops = append(sops, stageOp{op: op, read: 1})
+	}
+	for _, op := range opsR2 {
+		sops = append(sops, stageOp{op: op, read: 2})
+	}
+	return sops
+}
+
+// Operations to apply for counting pairs reaching sampling operation(s): operations up to the last
+// sampler. Other non thread-safe operations, not filtering pairs, are skipped. Operations before a
+// sampler must select the same pairs in both passes.
+func countingOps(sops []stageOp) ([]stageOp, []operations.Sampler, error) {
+	var countSops []stageOp
+	var samplers []operations.Sampler
+	last := -1
+	for i, sop := range sops {
+		if _, ok := operations.GetSampler(sop.op); ok {
+			last = i
+		}
+	}
+	for _, sop := range sops[:last+1] {
+		if !operations.IsDeterministic(sop.op) {
+			return nil, nil, fmt.Errorf("%s: non-reproducible operation before sampling (count) operation", sop.op.Label())
+		}
+		if sp, ok := operations.GetSampler(sop.op); ok {
+			samplers = append(samplers, sp)
+		} else if !sop.op.IsThreadSafe() {
+			continue
+		}
+		countSops = append(countSops, sop)
+	}
+	return countSops, samplers, nil
+}
+
+// Split operations into stages
+func newStages(sops []stageOp) []stage {
+	var stages []stage
+	for _, sop := range sops {
+		ts := sop.op.IsThreadSafe()
+		if len(stages) == 0 || stages[len(stages)-1].threadSafe != ts {
+			stages = append(stages, stage{threadSafe: ts})
+		}
+		stages[len(stages)-1].ops = append(stages[len(stages)-1].ops, sop)
+	}
+	// At least one stage for counting pairs
+	if len(stages) == 0 {
+		stages = append(stages, stage{threadSafe: true})
+	}
+	return stages
+}
+
+// Apply stage operation(s) to pair. Operations are skipped on reads already rejected (except
+// operations on both reads of pairs with an orphan read), and non thread-safe operations (samplers,
+// numbering) on pairs already rejected.
+func (st *stage) apply(p *fastq.ExtPair, ot *operations.OpStat, verboseLevel int) {
+	for _, sop := range st.ops {
+		failed := p.Failed[sop.read-1]
+		if failed && p.Ok && !p.Failed[2-sop.read] && operations.IsPairOp(sop.op) {
+			failed = false
+		}
+		if failed || (!p.Ok && !st.threadSafe) {
+			continue
+		}
+		if sop.op.Transform(p, sop.read, ot, verboseLevel) != 0 {
+			p.Failed[sop.read-1] = true
+			if operations.OnFail(sop.op) == operations.FailPair {
+				p.Ok = false
+			}
+			continue
+		}
+		if verboseLevel > 2 {
+			fmt.Println()
+		}
+	}
+}
+
+// Count pair as kept, orphan or rejected
+func countPair(p *fastq.ExtPair, ot *operations.OpStat) {
+	if p.Ok && !p.Failed[0] && !p.Failed[1] {
+		// Stat Out
+		ot.CountOut(p)
+		ot.KeptPair++
+	} else {
+		// Orphan read
+		if p.Ok && (!p.Failed[0] || !p.Failed[1]) {
+			if !p.Failed[0] {
+				p.Single = 1
+				ot.OrphanR1++
+			} else {
+				p.Single = 2
+				ot.OrphanR2++
+			}
+		}
+		p.Ok = false
+	}
+	ot.TotalPair++
+}
+
+// Send pairs in order of ID
+func reorderPairs(in <-chan fastq.ExtPair, out chan<- fastq.ExtPair) {
+	var id, maxid uint64
+	pairCache := make(map[uint64]fastq.ExtPair)
+	for p := range in {
+		if p.ID == id {
+			out <- p
+			id++
+		} else {
+			pairCache[p.ID] = p
+		}
+		for {
+			if pc, ok := pairCache[id]; ok {
+				out <- pc
+				delete(pairCache, pc.ID)
+				id++
+			} else {
+				break
+			}
+		}
+	}
+	// Send back what remains in the cache
+	if len(pairCache) > 0 {
+		for k := range pairCache {
+			if k > maxid {
+				maxid = k
+			}
+		}
+		for i := id; i <= maxid; i++ {
+			pc := pairCache[i]
+			out <- pc
+		}
+	}
+}
+
+// Start reading pairs from input FASTQ files
+func startReader(g *errgroup.Group, gctx context.Context, fastqsR1 []string, fastqsR2 []string, fqCmdIn []string, paired bool, bufSize int, nWorker int) chan fastq.ExtPair {
+	chPair := make(chan fastq.ExtPair, nWorker*2)
+
+	g.Go(func() error {
+		defer close(chPair)
+		var id uint64
+		var fqr1, fqr2 *fastq.FqReader
+		var r1, r2 fastq.Record
+		var err error
+		for iFq := 0; iFq < len(fastqsR1); iFq++ {
+			// Open FASTQ files
+			fqr1, err = fastq.Ropen(fastqsR1[iFq], fqCmdIn, bufSize)
+			if err != nil {
+				return err
+			}
+			defer fqr1.Close()
+			if paired {
+				fqr2, err = fastq.Ropen(fastqsR2[iFq], fqCmdIn, bufSize)
+				if err != nil {
+					return err
+				}
+				defer fqr2.Close()
+				r2, err = fqr2.Iter()
+				if err != nil {
+					return err
+				}
+			} else {
+				fqr2 = new(fastq.FqReader)
+			}
+			// Iter reads
+			for r1, err = fqr1.Iter(); !fqr1.Done && !fqr2.Done; r1, err = fqr1.Iter() {
+				if err != nil {
+					return err
+				}
+				select {
+				case <-gctx.Done():
+					return gctx.Err()
+				case chPair <- fastq.ExtPair{ID: id, Ok: true, R1: r1, R2: r2}:
+				}
+				id++
+				if paired {
+					r2, err = fqr2.Iter()
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+	return chPair
+}
+
+// Start stage(s): operations are applied in declared order, in parallel for thread-safe operations.
+// Pairs are sent in order to the returned channel.
+func startStages(g *errgroup.Group, gctx context.Context, chPair chan fastq.ExtPair, stages []stage, newOpStat func() *operations.OpStat, nWorker int, verboseLevel int) (chan fastq.ExtPair, []*operations.OpStat) {
+	var ots []*operations.OpStat
+	chIn := chPair
+	for is := range stages {
+		st := &stages[is]
+		first := is == 0
+		last := is == len(stages)-1
+		// Pairs in order for non thread-safe operation(s)
+		nStageWorker := nWorker
+		if !st.threadSafe {
+			nStageWorker = 1
+			chUnordered := chIn
+			chOrdered := make(chan fastq.ExtPair, nWorker)
+			g.Go(func() error {
+				defer close(chOrdered)
+				reorderPairs(chUnordered, chOrdered)
+				return nil
+			})
+			chIn = chOrdered
+		}
+		if verboseLevel > 2 {
+			fmt.Printf("Stage %d: %d operation(s), thread-safe:%t\n", is+1, len(st.ops), st.threadSafe)
+		}
+		// Init. OpStat
+		stageOts := make([]*operations.OpStat, nStageWorker)
+		for i := range stageOts {
+			stageOts[i] = newOpStat()
+		}
+		ots = append(ots, stageOts...)
+		// Spawn worker goroutine(s)
+		chStageIn := chIn
+		chOut := make(chan fastq.ExtPair, nWorker)
+		g.Go(func() error {
+			defer close(chOut)
+			wg, wgctx := errgroup.WithContext(gctx)
+			for _, ot := range stageOts {
+				wg.Go(func() error {
+					for p := range chStageIn {
+						if first {
+							if verboseLevel > 2 {
+								fmt.Println("\n******** Read/pair", p.ID, "********")
+							}
+							// Stat In
+							ot.CountIn(&p)
+						}
+						// Apply operation(s)
+						st.apply(&p, ot, verboseLevel)
+						if last {
+							countPair(&p, ot)
+						}
+						// Send to next stage
+						select {
+						case <-wgctx.Done():
+							return wgctx.Err()
+						case chOut <- p:
+						}
+					}
+					return nil
+				})
+			}
+			// Wait for the workers to finish
+			return wg.Wait()
+		})
+		chIn = chOut
+	}
+
+	// Start receiving channel
+	chFinal := make(chan fastq.ExtPair, nWorker*10000)
+	g.Go(func() error {
+		defer close(chFinal)
+		reorderPairs(chIn, chFinal)
+		return nil
+	})
+	return chFinal, ots
+}
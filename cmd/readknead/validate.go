@@ -0,0 +1,167 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+	"git.sr.ht/~vejnar/ReadKnead/lib/operations"
+)
+
+// Check input files, print operations with all their parameters and output files without
+// processing reads. Problems found are returned.
+func validateRun(w io.Writer, fastqsR1 []string, fastqsR2 []string, fqCmdIn []string, bufSize int, fqPathOut string, fqFnameOutR1 string, fqFnameOutR2 string, fqFnameOutSingleR1 string, fqFnameOutSingleR2 string, fqFnameOutRejectedR1 string, fqFnameOutRejectedR2 string, opsR1 []operations.Operation, opsR2 []operations.Operation, paired bool) []error {
+	var errs []error
+
+	// Input
+	fmt.Fprintln(w, "Input")
+	if paired && len(fastqsR1) != len(fastqsR2) {
+		errs = append(errs, fmt.Errorf("%d read 1 and %d read 2 input files", len(fastqsR1), len(fastqsR2)))
+	}
+	for i, fq := range fastqsR1 {
+		fmt.Fprintf(w, "  read 1: %s\n", fq)
+		if paired && i < len(fastqsR2) {
+			fmt.Fprintf(w, "  read 2: %s\n", fastqsR2[i])
+		}
+	}
+	missing := make(map[string]bool)
+	for _, fq := range append(append([]string{}, fastqsR1...), fastqsR2...) {
+		if _, err := os.Stat(fq); err != nil {
+			errs = append(errs, err)
+			missing[fq] = true
+		}
+	}
+	// Read 1 and 2 files pair up: same first read ID
+	for i := 0; paired && i < len(fastqsR1) && i < len(fastqsR2); i++ {
+		if missing[fastqsR1[i]] || missing[fastqsR2[i]] {
+			continue
+		}
+		if err := checkPair(fastqsR1[i], fastqsR2[i], fqCmdIn, bufSize); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Operations
+	for r, ops := range [][]operations.Operation{opsR1, opsR2} {
+		if len(ops) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "Operations read %d\n", r+1)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		describeOps(tw, ops, "  ")
+		tw.Flush()
+	}
+
+	// Output
+	dpxNames, _ := getDpxNames(opsR1, opsR2)
+	var fnames []string
+	if fqPathOut != "" || fqFnameOutR1 != "" || fqFnameOutR2 != "" {
+		if len(fastqsR1) > 0 && fqFnameOutR1 == "" {
+			fqFnameOutR1 = filepath.Base(fastqsR1[0])
+		}
+		fnames = append(fnames, fqFnameOutR1)
+		if paired {
+			if len(fastqsR2) > 0 && fqFnameOutR2 == "" {
+				fqFnameOutR2 = filepath.Base(fastqsR2[0])
+			}
+			fnames = append(fnames, fqFnameOutR2)
+		}
+	}
+	if paired && (fqFnameOutSingleR1 != "" || fqFnameOutSingleR2 != "") {
+		if fqFnameOutSingleR1 == "" || fqFnameOutSingleR2 == "" {
+			errs = append(errs, fmt.Errorf("output FASTQ files for single reads 1 and 2 required"))
+		}
+		fnames = append(fnames, fqFnameOutSingleR1, fqFnameOutSingleR2)
+	}
+	if fqFnameOutRejectedR1 != "" || fqFnameOutRejectedR2 != "" {
+		if fqFnameOutRejectedR1 == "" || (paired && fqFnameOutRejectedR2 == "") {
+			errs = append(errs, fmt.Errorf("output FASTQ files for rejected reads 1 and 2 required"))
+		}
+		fnames = append(fnames, fqFnameOutRejectedR1)
+		if paired {
+			fnames = append(fnames, fqFnameOutRejectedR2)
+		}
+	}
+	fmt.Fprintln(w, "Output")
+	for _, fname := range fnames {
+		if fname == "" {
+			continue
+		}
+		for _, n := range dpxNames {
+			fmt.Fprintf(w, "  %s\n", dpxPath(fqPathOut, fname, n))
+		}
+	}
+	return errs
+}
+
+// Check that read 1 and 2 files start with the same read
+func checkPair(fastqR1 string, fastqR2 string, fqCmdIn []string, bufSize int) error {
+	id1, err := firstReadID(fastqR1, fqCmdIn, bufSize)
+	if err != nil {
+		return err
+	}
+	id2, err := firstReadID(fastqR2, fqCmdIn, bufSize)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(id1, id2) {
+		return fmt.Errorf("%s and %s do not pair up: first reads %s and %s", fastqR1, fastqR2, id1, id2)
+	}
+	return nil
+}
+
+// ID of first read in FASTQ file: name without comment and mate suffix (/1 or /2)
+func firstReadID(fpath string, fqCmdIn []string, bufSize int) ([]byte, error) {
+	fqr, err := fastq.Ropen(fpath, fqCmdIn, bufSize)
+	if err != nil {
+		return nil, err
+	}
+	defer fqr.Close()
+	r, err := fqr.Iter()
+	if err != nil || fqr.Done {
+		return nil, err
+	}
+	name := r.Name
+	if i := bytes.IndexAny(name, " \t"); i != -1 {
+		name = name[:i]
+	}
+	if bytes.HasSuffix(name, []byte("/1")) || bytes.HasSuffix(name, []byte("/2")) {
+		name = name[:len(name)-2]
+	}
+	return name, nil
+}
+
+// Print operations (and sub-operations) with their parameters
+func describeOps(w io.Writer, ops []operations.Operation, indent string) {
+	for i, op := range ops {
+		fmt.Fprintf(w, "%s[%d] %s\tlabel:%s\n", indent, i, op.Name(), op.Label())
+		for _, p := range operations.Describe(op) {
+			fmt.Fprintf(w, "%s    %s\t%s\n", indent, p.Key, formatValue(p.Value))
+		}
+		if c, ok := op.(operations.Container); ok {
+			describeOps(w, c.Ops(), indent+"    ")
+		}
+	}
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
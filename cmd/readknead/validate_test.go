@@ -0,0 +1,79 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/operations"
+	"git.sr.ht/~vejnar/ReadKnead/lib/param"
+)
+
+func TestValidateRun(t *testing.T) {
+	param := param.Parameters{AsciiMin: 33, MaxQual: 43, Paired: true}
+	opsR1, err := operations.ReadOps(readAll(filepath.Join("testdata", "demultiplex.json")), param)
+	if err != nil {
+		t.Fatalf("failed reading json: %s", err)
+	}
+	testdata := func(fnames ...string) []string {
+		var paths []string
+		for _, fname := range fnames {
+			paths = append(paths, filepath.Join("testdata", fname))
+		}
+		return paths
+	}
+	for _, test := range []struct {
+		fastqsR1 []string
+		fastqsR2 []string
+		errs     []string
+	}{
+		{testdata("sample9_R1.fastq"), testdata("sample9_R2.fastq"), nil},
+		{
+			testdata("sample9_R1.fastq", "sample10_R1.fastq"),
+			testdata("sample9_R2.fastq"),
+			[]string{"2 read 1 and 1 read 2 input files"},
+		},
+		{
+			testdata("sample9_R1.fastq", "missing_R1.fastq"),
+			testdata("sample9_R2.fastq", "missing_R2.fastq"),
+			[]string{"stat testdata/missing_R1.fastq: no such file or directory", "stat testdata/missing_R2.fastq: no such file or directory"},
+		},
+		{
+			testdata("sample2_R1.fastq"),
+			testdata("sample9_R2.fastq"),
+			[]string{"testdata/sample2_R1.fastq and testdata/sample9_R2.fastq do not pair up: first reads HWI-ST1144:966:HKVXXXXX2:1:1101:1918:1964 and read0"},
+		},
+	} {
+		var b bytes.Buffer
+		errs := validateRun(&b, test.fastqsR1, test.fastqsR2, []string{}, 1048576, "out", "sample_[DPX]_R1.fastq", "sample_[DPX]_R2.fastq", "", "", "", "", opsR1, nil, true)
+		if len(errs) != len(test.errs) {
+			t.Errorf("%v: got errors %v, expected %v", test.fastqsR1, errs, test.errs)
+			continue
+		}
+		for i, err := range errs {
+			if err.Error() != test.errs[i] {
+				t.Errorf("%v: got error %q, expected %q", test.fastqsR1, err, test.errs[i])
+			}
+		}
+		// Demultiplexed outputs
+		output := b.String()[strings.Index(b.String(), "Output\n"):]
+		expected := "Output\n"
+		for _, r := range []string{"R1", "R2"} {
+			for _, name := range []string{"undetermined", "GAGTA", "CTGAG"} {
+				expected += "  " + filepath.Join("out", "sample_"+name+"_"+r+".fastq") + "\n"
+			}
+		}
+		if output != expected {
+			t.Errorf("%v: got outputs %q, expected %q", test.fastqsR1, output, expected)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package bio
+
+import (
+	"math"
+)
+
+// Index of nucleotide in 2-bit encoding (-1 if not A, C, G or T)
+func ntIndex(nt byte) int {
+	switch nt {
+	case 'A', 'a':
+		return 0
+	case 'C', 'c':
+		return 1
+	case 'G', 'g':
+		return 2
+	case 'T', 't':
+		return 3
+	}
+	return -1
+}
+
+// Count trinucleotides in seq. Trinucleotides including a non-ACGT nucleotide are skipped.
+func countTriplets(seq []byte, counts *[64]int) int {
+	for i := range counts {
+		counts[i] = 0
+	}
+	n := 0
+	for i := 0; i+3 <= len(seq); i++ {
+		a, b, c := ntIndex(seq[i]), ntIndex(seq[i+1]), ntIndex(seq[i+2])
+		if a == -1 || b == -1 || c == -1 {
+			continue
+		}
+		counts[a<<4|b<<2|c]++
+		n++
+	}
+	return n
+}
+
+// Windows returns the start of the sliding windows over a sequence of length n.
+// Sequences shorter than window are covered by a single window.
+func windows(n int, window int, step int) []int {
+	if n <= window || window <= 0 {
+		return []int{0}
+	}
+	if step <= 0 {
+		step = window
+	}
+	var starts []int
+	for i := 0; i+window <= n; i += step {
+		starts = append(starts, i)
+	}
+	// Last window aligned on sequence end
+	if starts[len(starts)-1]+window < n {
+		starts = append(starts, n-window)
+	}
+	return starts
+}
+
+// DustScore returns the highest DUST score (scaled from 0 to 100) of all
+// windows. Low-complexity sequences have a high score.
+func DustScore(seq []byte, window int, step int) float64 {
+	var counts [64]int
+	var maxScore float64
+	for _, start := range windows(len(seq), window, step) {
+		end := min(start+window, len(seq))
+		if window <= 0 {
+			end = len(seq)
+		}
+		n := countTriplets(seq[start:end], &counts)
+		if n < 2 {
+			continue
+		}
+		sum := 0
+		for _, c := range counts {
+			sum += c * (c - 1) / 2
+		}
+		// Highest possible score is n/2 (single trinucleotide repeated)
+		score := float64(sum) / float64(n-1) / (float64(n) / 2.) * 100.
+		if score > maxScore {
+			maxScore = score
+		}
+	}
+	return maxScore
+}
+
+// Entropy returns the lowest trinucleotide Shannon entropy (scaled from 0 to
+// 100) of all windows. Low-complexity sequences have a low entropy.
+func Entropy(seq []byte, window int, step int) float64 {
+	var counts [64]int
+	minEntropy := 100.
+	for _, start := range windows(len(seq), window, step) {
+		end := min(start+window, len(seq))
+		if window <= 0 {
+			end = len(seq)
+		}
+		n := countTriplets(seq[start:end], &counts)
+		if n < 2 {
+			continue
+		}
+		var entropy float64
+		for _, c := range counts {
+			if c > 0 {
+				p := float64(c) / float64(n)
+				entropy -= p * math.Log(p)
+			}
+		}
+		// Highest possible entropy: all trinucleotides are different
+		entropy = entropy / math.Log(float64(min(64, n))) * 100.
+		if entropy < minEntropy {
+			minEntropy = entropy
+		}
+	}
+	return minEntropy
+}
@@ -18,3 +18,35 @@ func IsDNA(nt byte) bool {
 	}
 	return false
 }
+
+var complements [256]byte
+
+func init() {
+	for i := range complements {
+		complements[i] = byte(i)
+	}
+	for _, c := range []string{"AT", "CG", "RY", "KM", "BV", "DH", "at", "cg", "ry", "km", "bv", "dh"} {
+		complements[c[0]] = c[1]
+		complements[c[1]] = c[0]
+	}
+	complements['U'] = 'A'
+	complements['u'] = 'a'
+}
+
+// ReverseComplement returns the reverse complement of seq (IUPAC letters are complemented)
+func ReverseComplement(seq []byte) []byte {
+	rc := make([]byte, len(seq))
+	for i, nt := range seq {
+		rc[len(seq)-1-i] = complements[nt]
+	}
+	return rc
+}
+
+// Reverse returns a reversed copy of s
+func Reverse(s []byte) []byte {
+	r := make([]byte, len(s))
+	for i, c := range s {
+		r[len(s)-1-i] = c
+	}
+	return r
+}
@@ -0,0 +1,58 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package bio
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// ReadFasta returns the (uppercase) sequences of a FASTA file, compressed with gzip if path ends with .gz
+func ReadFasta(fpath string) ([][]byte, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(fpath, ".gz") {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+	var seqs [][]byte
+	var seq []byte
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) > 0 && line[0] == '>' {
+			if seq != nil {
+				seqs = append(seqs, seq)
+			}
+			seq = []byte{}
+		} else if seq != nil {
+			seq = append(seq, bytes.ToUpper(line)...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if seq != nil {
+		seqs = append(seqs, seq)
+	}
+	return seqs, nil
+}
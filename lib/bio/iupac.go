@@ -0,0 +1,128 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package bio
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Treatment of N in reads
+type NMode int
+
+const (
+	NMismatch NMode = iota
+	NMatch
+	NHalf
+)
+
+func (m NMode) String() string {
+	return []string{"mismatch", "match", "half"}[m]
+}
+
+func ParseNMode(s string) (NMode, error) {
+	switch s {
+	case "mismatch":
+		return NMismatch, nil
+	case "match":
+		return NMatch, nil
+	case "half":
+		return NHalf, nil
+	}
+	return NMismatch, fmt.Errorf("unknown N mode: %s", s)
+}
+
+// Bit mask of nucleotide(s) (A:1, C:2, G:4, T:8) coded by IUPAC letters
+var iupacMasks [256]uint8
+
+func init() {
+	for _, c := range []struct {
+		nt   byte
+		mask uint8
+	}{
+		{'A', 1}, {'C', 2}, {'G', 4}, {'T', 8}, {'U', 8},
+		{'R', 1 | 4}, {'Y', 2 | 8}, {'S', 2 | 4}, {'W', 1 | 8}, {'K', 4 | 8}, {'M', 1 | 2},
+		{'B', 2 | 4 | 8}, {'D', 1 | 4 | 8}, {'H', 1 | 2 | 8}, {'V', 1 | 2 | 4},
+		{'N', 15}, {'X', 15},
+	} {
+		iupacMasks[c.nt] = c.mask
+		iupacMasks[c.nt+'a'-'A'] = c.mask
+	}
+}
+
+// CompareIUPAC returns the match score of a read nucleotide to a reference
+// (adapter or barcode) nucleotide: 1 if nucleotides match, 0 if mismatch and
+// 0.5 for N in read with NHalf mode.
+func CompareIUPAC(read byte, ref byte, nMode NMode) float32 {
+	if iupacMasks[ref] == 15 {
+		return 1.
+	}
+	if read == 'N' || read == 'n' {
+		switch nMode {
+		case NMatch:
+			return 1.
+		case NHalf:
+			return 0.5
+		default:
+			return 0.
+		}
+	}
+	if iupacMasks[read]&iupacMasks[ref] != 0 || read == ref {
+		return 1.
+	}
+	return 0.
+}
+
+// IsDegenerate reports whether seq contains IUPAC letter(s) coding for more than one nucleotide
+func IsDegenerate(seq []byte) bool {
+	for _, nt := range seq {
+		switch iupacMasks[nt] {
+		case 1, 2, 4, 8:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// IsIUPAC reports whether seq is a (non-empty) sequence of IUPAC letters
+func IsIUPAC(seq []byte) bool {
+	for _, nt := range seq {
+		if iupacMasks[nt] == 0 {
+			return false
+		}
+	}
+	return len(seq) > 0
+}
+
+// EqualIUPAC reports whether all read nucleotides match the reference
+func EqualIUPAC(read []byte, ref []byte, nMode NMode) bool {
+	if len(read) != len(ref) {
+		return false
+	}
+	for i := range ref {
+		if CompareIUPAC(read[i], ref[i], nMode) != 1. {
+			return false
+		}
+	}
+	return true
+}
+
+// IndexIUPAC returns the index of the first exact match of ref in read, or -1
+func IndexIUPAC(read []byte, ref []byte, nMode NMode) int {
+	if nMode == NMismatch && !IsDegenerate(ref) {
+		return bytes.Index(read, ref)
+	}
+	for i := 0; i+len(ref) <= len(read); i++ {
+		if EqualIUPAC(read[i:i+len(ref)], ref, nMode) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,90 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package bio
+
+import (
+	"math/bits"
+)
+
+// Maximum number of references in KmerIndex
+const MaxKmerRefs = 64
+
+var ntCode [256]int8
+
+func init() {
+	for i := range ntCode {
+		ntCode[i] = -1
+	}
+	for i, nt := range []byte("ACGT") {
+		ntCode[nt] = int8(i)
+		ntCode[nt+'a'-'A'] = int8(i)
+	}
+}
+
+// KmerIndex maps canonical k-mers (k up to 32) to the references (up to MaxKmerRefs) they were
+// found in, as a bit mask. Once built, the index is read-only and can be shared between
+// goroutines.
+type KmerIndex struct {
+	K     int
+	kmers map[uint64]uint64
+}
+
+func NewKmerIndex(k int) *KmerIndex {
+	return &KmerIndex{K: k, kmers: make(map[uint64]uint64)}
+}
+
+// Len returns the number of k-mers in index
+func (idx *KmerIndex) Len() int {
+	return len(idx.kmers)
+}
+
+// Add k-mers of sequence seq from reference ref (below MaxKmerRefs)
+func (idx *KmerIndex) Add(seq []byte, ref int) {
+	idx.each(seq, func(kmer uint64) {
+		idx.kmers[kmer] |= 1 << uint(ref)
+	})
+}
+
+// Hits counts the k-mers of seq found per reference (k-mers shared by references are counted
+// for each). It returns the number of k-mers in seq.
+func (idx *KmerIndex) Hits(seq []byte, hits []int) int {
+	var n int
+	idx.each(seq, func(kmer uint64) {
+		n++
+		for refs := idx.kmers[kmer]; refs != 0; refs &= refs - 1 {
+			hits[bits.TrailingZeros64(refs)]++
+		}
+	})
+	return n
+}
+
+// Call fn on canonical k-mers of seq (k-mers with non-ACGT letters are skipped)
+func (idx *KmerIndex) each(seq []byte, fn func(uint64)) {
+	k := idx.K
+	mask := uint64(1)<<(2*uint(k)) - 1
+	if k == 32 {
+		mask = ^uint64(0)
+	}
+	shift := 2 * uint(k-1)
+	var fwd, rev uint64
+	var l int
+	for _, nt := range seq {
+		c := ntCode[nt]
+		if c < 0 {
+			l = 0
+			continue
+		}
+		fwd = (fwd<<2 | uint64(c)) & mask
+		rev = rev>>2 | uint64(3-c)<<shift
+		l++
+		if l >= k {
+			fn(min(fwd, rev))
+		}
+	}
+}
@@ -0,0 +1,37 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package bio
+
+import (
+	"testing"
+)
+
+func TestKmerIndexHits(t *testing.T) {
+	idx := NewKmerIndex(5)
+	// References 0 and 1 share ACGTACGAT
+	idx.Add([]byte("ACGTACGATT"), 0)
+	idx.Add([]byte("ACGTACGATCCTTGA"), 1)
+	for _, test := range []struct {
+		seq  string
+		n    int
+		hits []int
+	}{
+		{"ACGTACGAT", 5, []int{5, 5}},
+		{"ACGTACGATCCTTGA", 11, []int{5, 11}},
+		// Reverse complement
+		{"TCAAGGATCGTACGT", 11, []int{5, 11}},
+		{"GGGGGGGGG", 5, []int{0, 0}},
+	} {
+		hits := make([]int, 2)
+		n := idx.Hits([]byte(test.seq), hits)
+		if n != test.n || hits[0] != test.hits[0] || hits[1] != test.hits[1] {
+			t.Errorf("%s: got %d k-mers and hits %v, expected %d and %v", test.seq, n, hits, test.n, test.hits)
+		}
+	}
+}
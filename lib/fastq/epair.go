@@ -9,8 +9,40 @@
 package fastq
 
 type ExtPair struct {
-	ID     uint64
-	WID    int
-	Ok     bool
-	R1, R2 Record
+	ID          uint64
+	WID         int
+	Ok          bool
+	R1, R2      Record
+	Failed      [2]bool // Read 1 and/or 2 rejected by an operation
+	Single      int     // Read (1 or 2) kept without its mate
+	Rejection   string
+	Annotations map[string]string
+}
+
+// Reject records the label and reason of the first operation rejecting the pair
+func (p *ExtPair) Reject(label string, reason string) {
+	if p.Rejection == "" {
+		p.Rejection = label + ":" + reason
+	}
+}
+
+// Annotate records the outcome of operation label applied to read r
+func (p *ExtPair) Annotate(r int, label string, value string) {
+	if p.Annotations == nil {
+		p.Annotations = make(map[string]string)
+	}
+	p.Annotations[annotationKey(r, label)] = value
+}
+
+// Annotation returns the outcome of operation label applied to read r
+func (p *ExtPair) Annotation(r int, label string) (string, bool) {
+	value, ok := p.Annotations[annotationKey(r, label)]
+	return value, ok
+}
+
+func annotationKey(r int, label string) string {
+	if r == 2 {
+		return "r2:" + label
+	}
+	return "r1:" + label
 }
@@ -8,7 +8,28 @@
 
 package fastq
 
+import "bytes"
+
 // Record contains the data from a FASTQ record
 type Record struct {
 	Name, Seq, Qual []byte
 }
+
+// Index returns the index sequence(s) (i7 and i5 if dual-indexed) found in the
+// Casava 1.8 comment of the read name (e.g. 1:N:0:ACGTACGT+TTGGCCAA)
+func (r *Record) Index() [][]byte {
+	isp := bytes.IndexAny(r.Name, " \t")
+	if isp == -1 {
+		return nil
+	}
+	// Casava field only, without other comment fields (e.g. BX:Z:...)
+	comment := r.Name[isp+1:]
+	if isp = bytes.IndexAny(comment, " \t"); isp != -1 {
+		comment = comment[:isp]
+	}
+	fields := bytes.Split(comment, []byte(":"))
+	if len(fields) < 4 {
+		return nil
+	}
+	return bytes.Split(fields[3], []byte("+"))
+}
@@ -0,0 +1,39 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package fastq
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		index []string
+	}{
+		{"read1 1:N:0:ACGTACGT", []string{"ACGTACGT"}},
+		{"read1 1:N:0:ACGTACGT+TTGGCCAA", []string{"ACGTACGT", "TTGGCCAA"}},
+		{"read1 1:N:0:ACGT+TTGG BX:Z:AAACCCGG-1", []string{"ACGT", "TTGG"}},
+		{"read1\t1:N:0:ACGT\tBX:Z:AAACCCGG-1", []string{"ACGT"}},
+		{"read1 1:N:0", nil},
+		{"read1", nil},
+	} {
+		index := (&Record{Name: []byte(test.name)}).Index()
+		if len(index) != len(test.index) {
+			t.Errorf("%s: index %q, expected %q", test.name, index, test.index)
+			continue
+		}
+		for i := range index {
+			if !bytes.Equal(index[i], []byte(test.index[i])) {
+				t.Errorf("%s: index %q, expected %q", test.name, index, test.index)
+			}
+		}
+	}
+}
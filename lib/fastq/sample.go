@@ -0,0 +1,33 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package fastq
+
+// SampleSeqs returns the sequences of the first n reads found in FASTQ files
+func SampleSeqs(fpaths []string, cmd []string, bufSize int, n int) ([][]byte, error) {
+	var seqs [][]byte
+	for _, fpath := range fpaths {
+		fqr, err := Ropen(fpath, cmd, bufSize)
+		if err != nil {
+			return seqs, err
+		}
+		for r, err := fqr.Iter(); !fqr.Done; r, err = fqr.Iter() {
+			if err != nil {
+				fqr.Close()
+				return seqs, err
+			}
+			seqs = append(seqs, r.Seq)
+			if len(seqs) == n {
+				fqr.Close()
+				return seqs, nil
+			}
+		}
+		fqr.Close()
+	}
+	return seqs, nil
+}
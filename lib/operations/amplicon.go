@@ -0,0 +1,275 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package operations
+
+import (
+	"fmt"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
+	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+	"git.sr.ht/~vejnar/ReadKnead/lib/param"
+	"git.sr.ht/~vejnar/ReadKnead/lib/trim"
+
+	"github.com/buger/jsonparser"
+)
+
+const (
+	AmpliconFound = iota
+	AmpliconChimera
+	AmpliconUndetermined
+)
+
+var ampliconTypes = []string{"amplicon", "chimera", "undetermined"}
+
+type Amplicon struct {
+	Names       [][]byte
+	Forwards    [][]byte
+	Reverses    [][]byte
+	AmpliconsID []int
+	name        string
+	label       string
+	minScore    float32
+	nMode       bio.NMode
+	action      trim.Action
+	keep        []bool
+	demultiplex bool
+	paired      bool
+	annotate    bool
+}
+
+func NewAmplicon(data []byte, param param.Parameters) (*Amplicon, error) {
+	a := Amplicon{name: "amplicon", annotate: true, paired: param.Paired}
+	var err error
+	// primers
+	jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
+		if err == nil {
+			var name, forward, reverse string
+			name, err = jsonparser.GetString(value, "name")
+			if err != nil {
+				err = fmt.Errorf("%w: %v", err, "primer name")
+				return
+			}
+			forward, err = jsonparser.GetString(value, "forward")
+			if err != nil {
+				err = fmt.Errorf("%w: %v", err, "forward primer")
+				return
+			}
+			reverse, err = jsonparser.GetString(value, "reverse")
+			if err != nil {
+				err = fmt.Errorf("%w: %v", err, "reverse primer")
+				return
+			}
+			a.Names = append(a.Names, []byte(name))
+			a.Forwards = append(a.Forwards, []byte(forward))
+			a.Reverses = append(a.Reverses, []byte(reverse))
+		}
+	}, "primers")
+	if err != nil {
+		return &a, err
+	}
+	if len(a.Names) == 0 {
+		return &a, fmt.Errorf("primers not found")
+	}
+	// label
+	label, err := jsonparser.GetUnsafeString(data, "label")
+	if err != nil && err != jsonparser.KeyPathNotFoundError {
+		return &a, err
+	}
+	if label == "" {
+		a.label = a.name
+	} else {
+		a.label = label
+	}
+	minScore, err := jsonparser.GetFloat(data, "min_score")
+	if err == jsonparser.KeyPathNotFoundError {
+		a.minScore = 0.9
+	} else if err != nil {
+		return &a, err
+	} else {
+		a.minScore = float32(minScore)
+	}
+	nMode, err := jsonparser.GetString(data, "n_mode")
+	if err == jsonparser.KeyPathNotFoundError {
+		a.nMode = bio.NMismatch
+	} else if err != nil {
+		return &a, err
+	} else {
+		a.nMode, err = bio.ParseNMode(nMode)
+		if err != nil {
+			return &a, err
+		}
+	}
+	action, err := jsonparser.GetString(data, "action")
+	if err == jsonparser.KeyPathNotFoundError {
+		a.action = trim.ActionCut
+	} else if err != nil {
+		return &a, err
+	} else {
+		a.action, err = trim.ParseAction(action)
+		if err != nil {
+			return &a, err
+		}
+	}
+	err = nil
+	a.keep = make([]bool, len(ampliconTypes))
+	found := false
+	jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
+		if err == nil {
+			var k string
+			k, err = jsonparser.ParseString(value)
+			if err != nil {
+				return
+			}
+			for it, t := range ampliconTypes {
+				if k == t {
+					a.keep[it] = true
+				}
+			}
+			found = true
+		}
+	}, "keep")
+	if !found {
+		for i := range a.keep {
+			a.keep[i] = true
+		}
+	}
+	if err != nil {
+		return &a, err
+	}
+	demultiplex, err := jsonparser.GetBoolean(data, "demultiplex")
+	if err == jsonparser.KeyPathNotFoundError {
+		a.demultiplex = false
+	} else if err != nil {
+		return &a, err
+	} else {
+		a.demultiplex = demultiplex
+	}
+	return &a, nil
+}
+
+func (op *Amplicon) Name() string {
+	return op.name
+}
+
+func (op *Amplicon) Label() string {
+	return op.label
+}
+
+func (op *Amplicon) IsThreadSafe() bool {
+	return true
+}
+
+func (op *Amplicon) SetAnnotate(annotate bool) {
+	op.annotate = annotate
+}
+
+func (op *Amplicon) GetDpx(idx int) ([][]byte, int) {
+	if !op.demultiplex {
+		return [][]byte{}, idx
+	}
+	var names [][]byte
+	names = append(names, []byte("undetermined"))
+	idx++
+	op.AmpliconsID = op.AmpliconsID[:0]
+	for _, n := range op.Names {
+		names = append(names, n)
+		op.AmpliconsID = append(op.AmpliconsID, idx)
+		idx++
+	}
+	return names, idx
+}
+
+func (op *Amplicon) Describe() []Param {
+	return []Param{
+		{"primers", byteStrings(op.Names)},
+		{"min_score", op.minScore},
+		{"n_mode", op.nMode},
+		{"action", op.action},
+		{"keep", keepNames(op.keep, ampliconTypes)},
+		{"demultiplex", op.demultiplex},
+	}
+}
+
+// Find primer at 5' end of read
+func (op *Amplicon) find(read *fastq.Record, primers [][]byte, verboseLevel int) (int, int) {
+	trimType, trimIdx, _, trimSeq := trim.TrimMatch(read, primers, 0, 0, op.minScore, 5, op.nMode, trim.ActionNone, verboseLevel)
+	if trimType == trim.NoTrimType {
+		return -1, 0
+	}
+	return trimIdx, len(trimSeq)
+}
+
+func (op *Amplicon) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int) int {
+	if verboseLevel > 2 {
+		fmt.Printf("%s %s %s r%d\n%s\n%s\n", op.name, op.label, p.R1.Name, r, p.R1.Seq, p.R2.Seq)
+	}
+	ampliconType := AmpliconUndetermined
+	amplicon := -1
+	var length1, length2 int
+	if op.paired {
+		// Forward primer in read 1 and reverse primer in read 2, or the opposite
+		f1, lf1 := op.find(&p.R1, op.Forwards, verboseLevel)
+		r2, lr2 := op.find(&p.R2, op.Reverses, verboseLevel)
+		r1, lr1 := op.find(&p.R1, op.Reverses, verboseLevel)
+		f2, lf2 := op.find(&p.R2, op.Forwards, verboseLevel)
+		if verboseLevel > 3 {
+			fmt.Printf("> forward:%d,%d reverse:%d,%d\n", f1, r2, r1, f2)
+		}
+		if f1 != -1 && f1 == r2 {
+			ampliconType, amplicon, length1, length2 = AmpliconFound, f1, lf1, lr2
+		} else if r1 != -1 && r1 == f2 {
+			ampliconType, amplicon, length1, length2 = AmpliconFound, r1, lr1, lf2
+		} else if (f1 != -1 && r2 != -1) || (r1 != -1 && f2 != -1) {
+			ampliconType = AmpliconChimera
+		}
+	} else {
+		if f1, lf1 := op.find(&p.R1, op.Forwards, verboseLevel); f1 != -1 {
+			ampliconType, amplicon, length1 = AmpliconFound, f1, lf1
+		} else if r1, lr1 := op.find(&p.R1, op.Reverses, verboseLevel); r1 != -1 {
+			ampliconType, amplicon, length1 = AmpliconFound, r1, lr1
+		}
+	}
+	// Stats
+	var stat map[string]uint64
+	if r == 1 {
+		stat = ot.OpsR1[op.label]
+	} else {
+		stat = ot.OpsR2[op.label]
+	}
+	outcome := ampliconTypes[ampliconType]
+	if ampliconType == AmpliconFound {
+		outcome = string(op.Names[amplicon])
+	}
+	stat[outcome]++
+	if op.annotate {
+		p.Annotate(r, op.label, outcome)
+	}
+	if verboseLevel > 2 {
+		if ampliconType == AmpliconFound {
+			fmt.Printf("amplicon found:%s\n", op.Names[amplicon])
+		} else {
+			fmt.Println(ampliconTypes[ampliconType])
+		}
+	}
+	if !op.keep[ampliconType] {
+		p.Reject(op.label, outcome)
+		return 1
+	}
+	if ampliconType == AmpliconFound {
+		// Trim primers
+		op.action.Apply(&p.R1, length1, len(p.R1.Seq))
+		if op.paired {
+			op.action.Apply(&p.R2, length2, len(p.R2.Seq))
+		}
+		if op.demultiplex {
+			p.WID = op.AmpliconsID[amplicon]
+		}
+	}
+	return 0
+}
@@ -13,6 +13,7 @@ import (
 	"fmt"
 
 	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+	"git.sr.ht/~vejnar/ReadKnead/lib/trim"
 
 	"github.com/buger/jsonparser"
 )
@@ -24,6 +25,7 @@ type Clip struct {
 	length       int
 	addClipped   bool
 	addSeparator bool
+	action       trim.Action
 }
 
 func NewClip(data []byte) (*Clip, error) {
@@ -71,6 +73,17 @@ func NewClip(data []byte) (*Clip, error) {
 	} else {
 		c.addSeparator = addSeparator
 	}
+	action, err := jsonparser.GetString(data, "action")
+	if err == jsonparser.KeyPathNotFoundError {
+		c.action = trim.ActionCut
+	} else if err != nil {
+		return &c, err
+	} else {
+		c.action, err = trim.ParseAction(action)
+		if err != nil {
+			return &c, err
+		}
+	}
 	return &c, nil
 }
 
@@ -90,6 +103,16 @@ func (op *Clip) GetDpx(idx int) ([][]byte, int) {
 	return [][]byte{}, idx
 }
 
+func (op *Clip) Describe() []Param {
+	return []Param{
+		{"length", op.length},
+		{"end", op.end},
+		{"add_clipped", op.addClipped},
+		{"add_separator", op.addSeparator},
+		{"action", op.action},
+	}
+}
+
 func (op *Clip) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int) int {
 	if op.end == 5 {
 		if r == 1 {
@@ -98,6 +121,7 @@ func (op *Clip) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int)
 			}
 			if len(p.R1.Seq) < op.length {
 				ot.OpsR1[op.label]["too_short"]++
+				p.Reject(op.label, "too_short")
 				return 1
 			} else {
 				if op.addClipped {
@@ -108,8 +132,7 @@ func (op *Clip) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int)
 					p.R1.Name = append(p.R1.Name, p.R1.Seq[:op.length]...)
 					p.R2.Name = append(p.R2.Name, p.R1.Seq[:op.length]...)
 				}
-				p.R1.Seq = p.R1.Seq[op.length:]
-				p.R1.Qual = p.R1.Qual[op.length:]
+				op.action.Apply(&p.R1, op.length, len(p.R1.Seq))
 				if verboseLevel > 2 {
 					fmt.Printf("> %s\n", p.R1.Seq)
 				}
@@ -121,6 +144,7 @@ func (op *Clip) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int)
 			}
 			if len(p.R2.Seq) < op.length {
 				ot.OpsR2[op.label]["too_short"]++
+				p.Reject(op.label, "too_short")
 				return 1
 			} else {
 				if op.addClipped {
@@ -131,8 +155,7 @@ func (op *Clip) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int)
 					p.R1.Name = append(p.R1.Name, p.R2.Seq[:op.length]...)
 					p.R2.Name = append(p.R2.Name, p.R2.Seq[:op.length]...)
 				}
-				p.R2.Seq = p.R2.Seq[op.length:]
-				p.R2.Qual = p.R2.Qual[op.length:]
+				op.action.Apply(&p.R2, op.length, len(p.R2.Seq))
 				if verboseLevel > 2 {
 					fmt.Printf("> %s\n", p.R2.Seq)
 				}
@@ -147,6 +170,7 @@ func (op *Clip) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int)
 			clipIndex := len(p.R1.Seq) - op.length
 			if clipIndex < 0 {
 				ot.OpsR1[op.label]["too_short"]++
+				p.Reject(op.label, "too_short")
 				return 1
 			} else {
 				if op.addClipped {
@@ -157,8 +181,7 @@ func (op *Clip) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int)
 					p.R1.Name = append(p.R1.Name, p.R1.Seq[clipIndex:]...)
 					p.R2.Name = append(p.R2.Name, p.R1.Seq[clipIndex:]...)
 				}
-				p.R1.Seq = p.R1.Seq[:clipIndex]
-				p.R1.Qual = p.R1.Qual[:clipIndex]
+				op.action.Apply(&p.R1, 0, clipIndex)
 				if verboseLevel > 2 {
 					fmt.Printf("> %s\n", p.R1.Seq)
 				}
@@ -171,6 +194,7 @@ func (op *Clip) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int)
 			clipIndex := len(p.R2.Seq) - op.length
 			if clipIndex < 0 {
 				ot.OpsR2[op.label]["too_short"]++
+				p.Reject(op.label, "too_short")
 				return 1
 			} else {
 				if op.addClipped {
@@ -181,8 +205,7 @@ func (op *Clip) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int)
 					p.R1.Name = append(p.R1.Name, p.R2.Seq[clipIndex:]...)
 					p.R2.Name = append(p.R2.Name, p.R2.Seq[clipIndex:]...)
 				}
-				p.R2.Seq = p.R2.Seq[:clipIndex]
-				p.R2.Qual = p.R2.Qual[:clipIndex]
+				op.action.Apply(&p.R2, 0, clipIndex)
 				if verboseLevel > 2 {
 					fmt.Printf("> %s\n", p.R2.Seq)
 				}
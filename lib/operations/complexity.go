@@ -0,0 +1,175 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package operations
+
+import (
+	"fmt"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
+	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+
+	"github.com/buger/jsonparser"
+)
+
+type Complexity struct {
+	name       string
+	label      string
+	function   string
+	window     int
+	step       int
+	maxDust    float64
+	minEntropy float64
+	pair       bool
+}
+
+func NewComplexity(data []byte) (*Complexity, error) {
+	c := Complexity{name: "complexity"}
+	// label
+	label, err := jsonparser.GetUnsafeString(data, "label")
+	if err != nil && err != jsonparser.KeyPathNotFoundError {
+		return &c, err
+	}
+	if label == "" {
+		c.label = c.name
+	} else {
+		c.label = label
+	}
+	// function
+	function, err := jsonparser.GetString(data, "function")
+	if err == jsonparser.KeyPathNotFoundError {
+		c.function = "dust"
+	} else if err != nil {
+		return &c, err
+	} else {
+		c.function = function
+	}
+	if !(c.function == "dust" || c.function == "entropy" || c.function == "dust_entropy") {
+		return &c, fmt.Errorf("unknown function: %s", c.function)
+	}
+	// window
+	window, err := jsonparser.GetInt(data, "window")
+	if err == jsonparser.KeyPathNotFoundError {
+		c.window = 64
+	} else if err != nil {
+		return &c, err
+	} else {
+		c.window = int(window)
+	}
+	// step
+	step, err := jsonparser.GetInt(data, "step")
+	if err == jsonparser.KeyPathNotFoundError {
+		c.step = c.window / 2
+	} else if err != nil {
+		return &c, err
+	} else {
+		c.step = int(step)
+	}
+	// maxDust
+	maxDust, err := jsonparser.GetFloat(data, "max_dust")
+	if err == jsonparser.KeyPathNotFoundError {
+		c.maxDust = 7.
+	} else if err != nil {
+		return &c, err
+	} else {
+		c.maxDust = maxDust
+	}
+	// minEntropy
+	minEntropy, err := jsonparser.GetFloat(data, "min_entropy")
+	if err == jsonparser.KeyPathNotFoundError {
+		c.minEntropy = 70.
+	} else if err != nil {
+		return &c, err
+	} else {
+		c.minEntropy = minEntropy
+	}
+	// pair
+	pair, err := jsonparser.GetBoolean(data, "pair")
+	if err == jsonparser.KeyPathNotFoundError {
+		c.pair = false
+	} else if err != nil {
+		return &c, err
+	} else {
+		c.pair = pair
+	}
+	return &c, nil
+}
+
+func (op *Complexity) Name() string {
+	return op.name
+}
+
+func (op *Complexity) Label() string {
+	return op.label
+}
+
+func (op *Complexity) IsThreadSafe() bool {
+	return true
+}
+
+func (op *Complexity) GetDpx(idx int) ([][]byte, int) {
+	return [][]byte{}, idx
+}
+
+func (op *Complexity) Describe() []Param {
+	return []Param{
+		{"function", op.function},
+		{"window", op.window},
+		{"step", op.step},
+		{"max_dust", op.maxDust},
+		{"min_entropy", op.minEntropy},
+		{"pair", op.pair},
+	}
+}
+
+func (op *Complexity) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int) int {
+	var seq []byte
+	if op.pair {
+		seq = joinThree(p.R1.Seq, []byte("N"), p.R2.Seq)
+	} else if r == 1 {
+		seq = p.R1.Seq
+	} else {
+		seq = p.R2.Seq
+	}
+	if verboseLevel > 2 {
+		fmt.Printf("%s %s r%d\n%s\n", op.name, op.label, r, seq)
+	}
+	lowComplexity := false
+	if op.function == "dust" || op.function == "dust_entropy" {
+		dust := bio.DustScore(seq, op.window, op.step)
+		if verboseLevel > 3 {
+			fmt.Printf("> dust:%.2f\n", dust)
+		}
+		if dust > op.maxDust {
+			lowComplexity = true
+		}
+	}
+	if op.function == "entropy" || op.function == "dust_entropy" {
+		entropy := bio.Entropy(seq, op.window, op.step)
+		if verboseLevel > 3 {
+			fmt.Printf("> entropy:%.2f\n", entropy)
+		}
+		if entropy < op.minEntropy {
+			lowComplexity = true
+		}
+	}
+	outcome := "passed"
+	if lowComplexity {
+		outcome = "low_complexity"
+	}
+	if r == 1 {
+		ot.OpsR1[op.label][outcome]++
+	} else {
+		ot.OpsR2[op.label][outcome]++
+	}
+	if lowComplexity {
+		p.Reject(op.label, outcome)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package operations
+
+import (
+	"testing"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+	"git.sr.ht/~vejnar/ReadKnead/lib/param"
+)
+
+func TestComplexityStats(t *testing.T) {
+	ops, err := ReadOps([]byte(`[{"name": "complexity", "function": "dust", "max_dust": 7.0}]`), param.Parameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ot := NewOpStat("", "", "", "", 0, 0, 0, false, ops, nil)
+	for _, seq := range []string{
+		"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+		"ACGTTGCAGGCTAACGTTAGCCATGCAATCGGATCCTAGCTTAGGCATCGATGCAA",
+		"TTGACCAGTAGGACATGCAAGCTAGCTAGGATCCGATCGATTGCAACGTACGATCG",
+	} {
+		p := fastq.ExtPair{Ok: true, R1: fastq.Record{Seq: []byte(seq)}}
+		ops[0].Transform(&p, 1, ot, 0)
+	}
+	for outcome, count := range map[string]uint64{"low_complexity": 1, "passed": 2} {
+		if ot.OpsR1["complexity"][outcome] != count {
+			t.Errorf("%s: %d, expected %d", outcome, ot.OpsR1["complexity"][outcome], count)
+		}
+	}
+}
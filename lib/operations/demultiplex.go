@@ -9,14 +9,24 @@
 package operations
 
 import (
+	"bytes"
 	"fmt"
 
 	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
 	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+	"git.sr.ht/~vejnar/ReadKnead/lib/param"
 
 	"github.com/buger/jsonparser"
 )
 
+const (
+	DpxSequence = iota
+	DpxName
+	DpxHeader
+)
+
+var dpxSources = []string{"sequence", "name", "header"}
+
 type Demultiplex struct {
 	Barcodes     [][]byte
 	BarcodesID   []int
@@ -26,11 +36,15 @@ type Demultiplex struct {
 	barcodeIdx   int
 	lengthLigand int
 	maxMismatch  int
-	useSeq       bool
+	nMode        bio.NMode
+	source       int
+	indexes      [][][]byte
+	pipelines    map[int][]Operation
+	annotate     bool
 }
 
-func NewDemultiplex(data []byte) (*Demultiplex, error) {
-	d := Demultiplex{name: "demultiplex"}
+func NewDemultiplex(data []byte, param param.Parameters) (*Demultiplex, error) {
+	d := Demultiplex{name: "demultiplex", annotate: true}
 	var err error
 	// barcodes
 	jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
@@ -58,21 +72,42 @@ func NewDemultiplex(data []byte) (*Demultiplex, error) {
 	}
 	end, err := jsonparser.GetInt(data, "end")
 	if err == jsonparser.KeyPathNotFoundError {
-		d.useSeq = false
+		d.source = DpxName
 	} else if err != nil {
 		return &d, err
 	} else {
 		d.end = int(end)
-		d.useSeq = true
+		d.source = DpxSequence
 	}
 	bcidx, err := jsonparser.GetInt(data, "barcode_idx")
 	if err == jsonparser.KeyPathNotFoundError {
-		d.useSeq = true
+		d.source = DpxSequence
 	} else if err != nil {
 		return &d, err
 	} else {
 		d.barcodeIdx = int(bcidx)
-		d.useSeq = false
+		d.source = DpxName
+	}
+	source, err := jsonparser.GetString(data, "source")
+	if err != nil && err != jsonparser.KeyPathNotFoundError {
+		return &d, err
+	} else if err == nil {
+		d.source = -1
+		for it, t := range dpxSources {
+			if source == t {
+				d.source = it
+			}
+		}
+		if d.source == -1 {
+			return &d, fmt.Errorf("unknown source: %s", source)
+		}
+	}
+	// Index(es) in read header: i7 or i7+i5
+	if d.source == DpxHeader {
+		d.end = 0
+		for _, bc := range d.Barcodes {
+			d.indexes = append(d.indexes, bytes.Split(bc, []byte("+")))
+		}
 	}
 	maxMismatch, err := jsonparser.GetInt(data, "max_mismatch")
 	if err == jsonparser.KeyPathNotFoundError {
@@ -90,6 +125,52 @@ func NewDemultiplex(data []byte) (*Demultiplex, error) {
 	} else {
 		d.lengthLigand = int(lengthLigand)
 	}
+	nMode, err := jsonparser.GetString(data, "n_mode")
+	if err == jsonparser.KeyPathNotFoundError {
+		d.nMode = bio.NMismatch
+	} else if err != nil {
+		return &d, err
+	} else {
+		d.nMode, err = bio.ParseNMode(nMode)
+		if err != nil {
+			return &d, err
+		}
+	}
+	// Sub-pipeline(s) per barcode
+	err = jsonparser.ObjectEach(data, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
+		ibc := -1
+		for i, bc := range d.Barcodes {
+			if string(bc) == string(key) {
+				ibc = i
+			}
+		}
+		if ibc == -1 {
+			return fmt.Errorf("unknown barcode in pipelines: %s", key)
+		}
+		ops, err := readOps(value, param, string(key)+"/")
+		if err != nil {
+			return err
+		}
+		for _, op := range ops {
+			if !op.IsThreadSafe() {
+				return fmt.Errorf("non thread-safe operation %s not available in pipelines", op.Label())
+			}
+			if OnFail(op) == FailMate {
+				return fmt.Errorf("on_fail mate not available in pipelines: %s", op.Label())
+			}
+			if names, _ := op.GetDpx(0); len(names) > 0 {
+				return fmt.Errorf("demultiplexing operation %s not available in pipelines", op.Label())
+			}
+		}
+		if d.pipelines == nil {
+			d.pipelines = make(map[int][]Operation)
+		}
+		d.pipelines[ibc] = ops
+		return nil
+	}, "pipelines")
+	if err != nil && err != jsonparser.KeyPathNotFoundError {
+		return &d, err
+	}
 	return &d, nil
 }
 
@@ -105,10 +186,15 @@ func (op *Demultiplex) IsThreadSafe() bool {
 	return true
 }
 
+func (op *Demultiplex) SetAnnotate(annotate bool) {
+	op.annotate = annotate
+}
+
 func (op *Demultiplex) GetDpx(idx int) ([][]byte, int) {
 	var names [][]byte
 	names = append(names, []byte("undetermined"))
 	idx++
+	op.BarcodesID = op.BarcodesID[:0]
 	for _, b := range op.Barcodes {
 		names = append(names, b)
 		op.BarcodesID = append(op.BarcodesID, idx)
@@ -117,19 +203,49 @@ func (op *Demultiplex) GetDpx(idx int) ([][]byte, int) {
 	return names, idx
 }
 
+func (op *Demultiplex) Describe() []Param {
+	params := []Param{
+		{"barcodes", byteStrings(op.Barcodes)},
+		{"source", dpxSources[op.source]},
+	}
+	switch op.source {
+	case DpxSequence:
+		params = append(params, Param{"end", op.end})
+	case DpxName:
+		params = append(params, Param{"barcode_idx", op.barcodeIdx})
+	}
+	params = append(params, []Param{
+		{"max_mismatch", op.maxMismatch},
+		{"length_ligand", op.lengthLigand},
+		{"n_mode", op.nMode},
+	}...)
+	var pipelines []string
+	for ibc, bc := range op.Barcodes {
+		if _, ok := op.pipelines[ibc]; ok {
+			pipelines = append(pipelines, string(bc))
+		}
+	}
+	if len(pipelines) > 0 {
+		params = append(params, Param{"pipelines", pipelines})
+	}
+	return params
+}
+
 func (op *Demultiplex) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int) int {
 	var seq []byte
 	var bcs [][]byte
 	var okSeq bool
 	bestBarcodeSeq := []byte("undetermined")
 	bestBarcode := -1
+	bestBarcodeIdx := -1
 	if r == 1 {
 		if verboseLevel > 2 {
 			fmt.Printf("%s %s %s r%d\n%s\n", op.name, op.label, p.R1.Name, r, p.R1.Seq)
 		}
 		for ibc, bc := range op.Barcodes {
 			okSeq = false
-			if op.useSeq {
+			switch op.source {
+			case DpxSequence:
 				if len(p.R1.Seq) > len(bc) {
 					if op.end == 5 {
 						seq = p.R1.Seq[:len(bc)]
@@ -138,28 +254,35 @@ func (op *Demultiplex) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLev
 					}
 					okSeq = true
 				}
-			} else {
+			case DpxName:
 				bcs = GetBarcodes(p.R1.Name)
 				seq = bcs[op.barcodeIdx]
 				if len(seq) == len(bc) {
 					okSeq = true
 				}
+			case DpxHeader:
+				bcs = p.R1.Index()
+				seq = bytes.Join(bcs, []byte("+"))
+				okSeq = op.matchIndexLength(bcs, ibc)
 			}
 			if okSeq {
 				// Count mismatch(es) with barcode
-				nmismatch := 0
-				for i := 0; i < len(bc); i++ {
-					if bc[i] != seq[i] {
-						nmismatch++
+				var nmismatch float32
+				if op.source == DpxHeader {
+					nmismatch = op.indexMismatch(bcs, ibc)
+				} else {
+					for i := 0; i < len(bc); i++ {
+						nmismatch += 1 - bio.CompareIUPAC(seq[i], bc[i], op.nMode)
 					}
 				}
 				// Demultiplex
-				if nmismatch <= op.maxMismatch {
+				if nmismatch <= float32(op.maxMismatch) {
 					bestBarcode = op.BarcodesID[ibc]
+					bestBarcodeIdx = ibc
 					bestBarcodeSeq = bc
 				}
 				if verboseLevel > 3 {
-					fmt.Printf("+ %s barcode:%s nmismatch:%d\n", bc, seq, nmismatch)
+					fmt.Printf("+ %s barcode:%s nmismatch:%.1f\n", bc, seq, nmismatch)
 				}
 				if nmismatch == 0 {
 					break
@@ -172,7 +295,8 @@ func (op *Demultiplex) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLev
 		}
 		for ibc, bc := range op.Barcodes {
 			okSeq = false
-			if op.useSeq {
+			switch op.source {
+			case DpxSequence:
 				if len(p.R2.Seq) > len(bc) {
 					if op.end == 5 {
 						seq = p.R2.Seq[:len(bc)]
@@ -181,28 +305,35 @@ func (op *Demultiplex) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLev
 					}
 					okSeq = true
 				}
-			} else {
+			case DpxName:
 				bcs = GetBarcodes(p.R2.Name)
 				seq = bcs[op.barcodeIdx]
 				if len(seq) == len(bc) {
 					okSeq = true
 				}
+			case DpxHeader:
+				bcs = p.R2.Index()
+				seq = bytes.Join(bcs, []byte("+"))
+				okSeq = op.matchIndexLength(bcs, ibc)
 			}
 			if okSeq {
 				// Count mismatch(es) with barcode
-				nmismatch := 0
-				for i := 0; i < len(bc); i++ {
-					if bc[i] != seq[i] {
-						nmismatch++
+				var nmismatch float32
+				if op.source == DpxHeader {
+					nmismatch = op.indexMismatch(bcs, ibc)
+				} else {
+					for i := 0; i < len(bc); i++ {
+						nmismatch += 1 - bio.CompareIUPAC(seq[i], bc[i], op.nMode)
 					}
 				}
 				// Demultiplex
-				if nmismatch <= op.maxMismatch {
+				if nmismatch <= float32(op.maxMismatch) {
 					bestBarcode = op.BarcodesID[ibc]
+					bestBarcodeIdx = ibc
 					bestBarcodeSeq = bc
 				}
 				if verboseLevel > 3 {
-					fmt.Printf("+ %s barcode:%s nmismatch:%d\n", bc, seq, nmismatch)
+					fmt.Printf("+ %s barcode:%s nmismatch:%.1f\n", bc, seq, nmismatch)
 				}
 				if nmismatch == 0 {
 					break
@@ -210,27 +341,77 @@ func (op *Demultiplex) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLev
 			}
 		}
 	}
+	// Stats
+	op.stat(p, r, ot, bestBarcodeSeq)
 	// Demultiplex if barcode found
 	if bestBarcode != -1 {
 		p.WID = bestBarcode
 		// Clip barcode and ligand
 		pc := Clip{name: op.name, label: op.label + "-clip", end: op.end, length: len(bestBarcodeSeq) + op.lengthLigand}
+		rejection := p.Rejection
 		pc.Transform(p, r, ot, verboseLevel)
+		// Read too short for clipping is not rejected
+		p.Rejection = rejection
 		if verboseLevel > 2 {
 			fmt.Printf("barcode found:%s\n", string(bestBarcodeSeq))
 		}
+		// Sample pipeline
+		for _, sop := range op.pipelines[bestBarcodeIdx] {
+			if sop.Transform(p, r, ot, verboseLevel) != 0 {
+				return 1
+			}
+		}
 	} else {
 		if verboseLevel > 2 {
 			fmt.Println("No barcode found")
 		}
 	}
-	// Stats
+	return 0
+}
+
+// Index(es) from read header with the length of barcode ibc index(es)
+func (op *Demultiplex) matchIndexLength(idxs [][]byte, ibc int) bool {
+	if len(idxs) < len(op.indexes[ibc]) {
+		return false
+	}
+	for i, bc := range op.indexes[ibc] {
+		if len(idxs[i]) != len(bc) {
+			return false
+		}
+	}
+	return true
+}
+
+// Maximum number of mismatch(es) between index(es) from read header and barcode ibc index(es)
+func (op *Demultiplex) indexMismatch(idxs [][]byte, ibc int) float32 {
+	var maxMismatch float32
+	for i, bc := range op.indexes[ibc] {
+		var nmismatch float32
+		for j := 0; j < len(bc); j++ {
+			nmismatch += 1 - bio.CompareIUPAC(idxs[i][j], bc[j], op.nMode)
+		}
+		maxMismatch = max(maxMismatch, nmismatch)
+	}
+	return maxMismatch
+}
+
+func (op *Demultiplex) stat(p *fastq.ExtPair, r int, ot *OpStat, barcode []byte) {
+	if op.annotate {
+		p.Annotate(r, op.label, string(barcode))
+	}
 	if r == 1 {
-		ot.OpsR1[op.label][string(bestBarcodeSeq)]++
+		ot.OpsR1[op.label][string(barcode)]++
 	} else {
-		ot.OpsR2[op.label][string(bestBarcodeSeq)]++
+		ot.OpsR2[op.label][string(barcode)]++
 	}
-	return 0
+}
+
+func (op *Demultiplex) Ops() []Operation {
+	var ops []Operation
+	for ibc := range op.Barcodes {
+		ops = append(ops, op.pipelines[ibc]...)
+	}
+	return ops
 }
 
 func GetBarcodes(name []byte) [][]byte {
@@ -72,6 +72,13 @@ func (op *Length) GetDpx(idx int) ([][]byte, int) {
 	return [][]byte{}, idx
 }
 
+func (op *Length) Describe() []Param {
+	return []Param{
+		{"min_length", op.minLength},
+		{"max_length", op.maxLength},
+	}
+}
+
 func (op *Length) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int) int {
 	if r == 1 {
 		if op.minLength != -1 && len(p.R1.Seq) < op.minLength {
@@ -79,12 +86,14 @@ func (op *Length) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel in
 				fmt.Printf("length r1 too_short %d\n", len(p.R1.Seq))
 			}
 			ot.OpsR1[op.label]["too_short"]++
+			p.Reject(op.label, "too_short")
 			return 1
 		} else if op.maxLength != -1 && len(p.R1.Seq) > op.maxLength {
 			if verboseLevel > 2 {
 				fmt.Printf("length r1 too_long %d\n", len(p.R1.Seq))
 			}
 			ot.OpsR1[op.label]["too_long"]++
+			p.Reject(op.label, "too_long")
 			return 1
 		} else {
 			return 0
@@ -95,12 +104,14 @@ func (op *Length) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel in
 				fmt.Printf("length r2 too_short %d\n", len(p.R2.Seq))
 			}
 			ot.OpsR2[op.label]["too_short"]++
+			p.Reject(op.label, "too_short")
 			return 1
 		} else if op.maxLength != -1 && len(p.R2.Seq) > op.maxLength {
 			if verboseLevel > 2 {
 				fmt.Printf("length r2 too_long %d\n", len(p.R2.Seq))
 			}
 			ot.OpsR2[op.label]["too_long"]++
+			p.Reject(op.label, "too_long")
 			return 1
 		} else {
 			return 0
@@ -10,6 +10,8 @@ package operations
 
 import (
 	"fmt"
+	"maps"
+	"strconv"
 
 	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
 	"git.sr.ht/~vejnar/ReadKnead/lib/param"
@@ -25,9 +27,182 @@ type Operation interface {
 	Transform(*fastq.ExtPair, int, *OpStat, int) int
 }
 
+// Constructor builds an operation from its JSON parameters
+type Constructor func(data []byte, param param.Parameters) (Operation, error)
+
+var registry = make(map[string]Constructor)
+
+// Register makes operation name available in pipelines. It panics if name is already registered.
+// Parameters are validated against params if given, otherwise only by the constructor.
+func Register(name string, constructor Constructor, params ...map[string]ParamSpec) {
+	if _, ok := registry[name]; ok {
+		panic("operations: operation registered twice: " + name)
+	}
+	registry[name] = constructor
+	if len(params) > 0 {
+		opParams[name] = make(map[string]ParamSpec)
+		for _, ps := range params {
+			maps.Copy(opParams[name], ps)
+		}
+	}
+}
+
+func init() {
+	Register("amplicon", func(data []byte, param param.Parameters) (Operation, error) { return NewAmplicon(data, param) })
+	Register("clip", func(data []byte, param param.Parameters) (Operation, error) { return NewClip(data) })
+	Register("complexity", func(data []byte, param param.Parameters) (Operation, error) { return NewComplexity(data) })
+	Register("demultiplex", func(data []byte, param param.Parameters) (Operation, error) { return NewDemultiplex(data, param) })
+	Register("length", func(data []byte, param param.Parameters) (Operation, error) { return NewLength(data) })
+	Register("orient", func(data []byte, param param.Parameters) (Operation, error) { return NewOrient(data, param) })
+	Register("quality", func(data []byte, param param.Parameters) (Operation, error) { return NewQuality(data, param) })
+	Register("random", func(data []byte, param param.Parameters) (Operation, error) { return NewRandom(data) })
+	Register("rename", func(data []byte, param param.Parameters) (Operation, error) { return NewRename(data) })
+	Register("screen", func(data []byte, param param.Parameters) (Operation, error) { return NewScreen(data, param) })
+	Register("trim", func(data []byte, param param.Parameters) (Operation, error) { return NewTrim(data, param) })
+}
+
+// Param is a parameter of an operation with its value (set or default)
+type Param struct {
+	Key   string
+	Value any
+}
+
+// Describer is implemented by operations listing their parameters, defaults included
+type Describer interface {
+	Describe() []Param
+}
+
+// Describe returns the parameters of operation op
+func Describe(op Operation) []Param {
+	if d, ok := op.(Describer); ok {
+		return d.Describe()
+	}
+	return nil
+}
+
+// Container is implemented by operations running sub-operations
+type Container interface {
+	Ops() []Operation
+}
+
+// Sampler is implemented by operations selecting pairs after counting them in a first pass
+type Sampler interface {
+	Counting() bool
+	StartSampling(dpxNames [][]byte)
+}
+
+// GetSampler returns the sampler of operation op (wrapped or not in a step) requiring a first pass
+func GetSampler(op Operation) (Sampler, bool) {
+	if s, ok := op.(*Step); ok {
+		op = s.Operation
+	}
+	if sp, ok := op.(Sampler); ok && sp.Counting() {
+		return sp, true
+	}
+	return nil, false
+}
+
+// Deterministic is implemented by operations whose outcome on a pair may change from one run to
+// another
+type Deterministic interface {
+	Deterministic() bool
+}
+
+// IsDeterministic reports if operation op (including sub-operations) gives the same outcome on a
+// pair in every run
+func IsDeterministic(op Operation) bool {
+	for _, o := range flattenOps([]Operation{op}) {
+		if s, ok := o.(*Step); ok {
+			o = s.Operation
+		}
+		if d, ok := o.(Deterministic); ok && !d.Deterministic() {
+			return false
+		}
+	}
+	return true
+}
+
+// PairOp is implemented by operations transforming both reads of a pair: they are applied to orphan
+// reads even if the read they are declared for was rejected
+type PairOp interface {
+	OnPair() bool
+}
+
+// IsPairOp reports if operation op (wrapped or not in a step) transforms both reads of a pair
+func IsPairOp(op Operation) bool {
+	if s, ok := op.(*Step); ok {
+		op = s.Operation
+	}
+	o, ok := op.(PairOp)
+	return ok && o.OnPair()
+}
+
+// SampleNamer is implemented by operations using the names of demultiplexed samples
+type SampleNamer interface {
+	SetDpxNames(dpxNames [][]byte)
+}
+
+// SetDpxNames sends the names of demultiplexed samples to operations (including sub-operations)
+func SetDpxNames(ops []Operation, dpxNames [][]byte) {
+	for _, op := range flattenOps(ops) {
+		if s, ok := op.(*Step); ok {
+			op = s.Operation
+		}
+		if sn, ok := op.(SampleNamer); ok {
+			sn.SetDpxNames(dpxNames)
+		}
+	}
+}
+
+// Annotator is implemented by operations recording their outcome on pairs for conditions
+type Annotator interface {
+	SetAnnotate(annotate bool)
+}
+
+// SetAnnotations limits the outcomes recorded on pairs to operations (including sub-operations)
+// whose label is used in a condition
+func SetAnnotations(opsR1 []Operation, opsR2 []Operation) {
+	flat := append(flattenOps(opsR1), flattenOps(opsR2)...)
+	labels := make(map[string]bool)
+	for _, op := range flat {
+		if s, ok := op.(*Step); ok && s.cond != nil && s.cond.label != "" {
+			labels[s.cond.label] = true
+		}
+	}
+	for _, op := range flat {
+		if s, ok := op.(*Step); ok {
+			op = s.Operation
+		}
+		if a, ok := op.(Annotator); ok {
+			a.SetAnnotate(labels[op.Label()])
+		}
+	}
+}
+
+// Operations including sub-operations
+func flattenOps(ops []Operation) []Operation {
+	var flat []Operation
+	for _, op := range ops {
+		flat = append(flat, op)
+		if c, ok := op.(Container); ok {
+			flat = append(flat, flattenOps(c.Ops())...)
+		}
+	}
+	return flat
+}
+
 func ReadOps(data []byte, param param.Parameters) ([]Operation, error) {
+	if err := validateOps(data, ""); err != nil {
+		return nil, err
+	}
+	return readOps(data, param, "")
+}
+
+// Read operations with labels prefixed by prefix
+func readOps(data []byte, param param.Parameters, prefix string) ([]Operation, error) {
 	var ops []Operation
 	var err error
+	labels := make(map[string]bool)
 	jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
 		if err == nil {
 			var op Operation
@@ -37,27 +212,47 @@ func ReadOps(data []byte, param param.Parameters) ([]Operation, error) {
 				err = fmt.Errorf("Operation \"name\" missing")
 				return
 			}
-			switch opName {
-			case "clip":
-				op, err = NewClip(value)
-			case "demultiplex":
-				op, err = NewDemultiplex(value)
-			case "length":
-				op, err = NewLength(value)
-			case "quality":
-				op, err = NewQuality(value, param)
-			case "random":
-				op, err = NewRandom(value)
-			case "rename":
-				op, err = NewRename(value)
-			case "trim":
-				op, err = NewTrim(value, param)
-			default:
+			if prefix != "" {
+				label, _ := jsonparser.GetString(value, "label")
+				if label == "" {
+					label = opName
+				}
+				value, err = jsonparser.Set(append([]byte{}, value...), []byte(strconv.Quote(prefix+label)), "label")
+				if err != nil {
+					return
+				}
+				if condLabel, err2 := jsonparser.GetString(value, "if", "label"); err2 == nil && labels[prefix+condLabel] {
+					value, err = jsonparser.Set(value, []byte(strconv.Quote(prefix+condLabel)), "if", "label")
+					if err != nil {
+						return
+					}
+				}
+			}
+			constructor, ok := registry[opName]
+			if !ok {
 				err = fmt.Errorf("unknown operation: %s", opName)
+				return
 			}
+			op, err = constructor(value, param)
 			if err != nil {
 				return
 			}
+			// Condition and failure mode
+			_, dataType, _, err2 := jsonparser.Get(value, "if")
+			_, _, _, err3 := jsonparser.Get(value, "on_fail")
+			if (err2 == nil && dataType == jsonparser.Object) || err3 == nil {
+				var step *Step
+				step, err = NewStep(op, value, param)
+				if err != nil {
+					return
+				}
+				if step.cond != nil && step.cond.label != "" && step.cond.read == 0 && prefix == "" && !labels[step.cond.label] {
+					err = fmt.Errorf("%s: unknown label in condition: %s", op.Label(), step.cond.label)
+					return
+				}
+				op = step
+			}
+			labels[op.Label()] = true
 			ops = append(ops, op)
 		}
 	})
@@ -80,3 +275,33 @@ func joinThree(a []byte, b []byte, c []byte) []byte {
 	copy(o[len(a)+len(b):], c)
 	return o
 }
+
+// Parameters of BK-tree trimming
+type bkParams struct {
+	epsilon      float64
+	epsilonIndel float64
+	minOverlap   int
+}
+
+func (bk bkParams) describe() []Param {
+	return []Param{{"epsilon", bk.epsilon}, {"epsilon_indel", bk.epsilonIndel}, {"min_overlap", bk.minOverlap}}
+}
+
+func byteStrings(bs [][]byte) []string {
+	var s []string
+	for _, b := range bs {
+		s = append(s, string(b))
+	}
+	return s
+}
+
+// Names of types kept
+func keepNames(keep []bool, types []string) []string {
+	var names []string
+	for i, k := range keep {
+		if k {
+			names = append(names, types[i])
+		}
+	}
+	return names
+}
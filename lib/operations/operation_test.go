@@ -0,0 +1,95 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package operations
+
+import (
+	"testing"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+	"git.sr.ht/~vejnar/ReadKnead/lib/param"
+
+	"github.com/buger/jsonparser"
+)
+
+// Operation registered out of the built-in operations
+type dummy struct {
+	label     string
+	minLength int64
+}
+
+func (op *dummy) Name() string                   { return "dummy" }
+func (op *dummy) Label() string                  { return op.label }
+func (op *dummy) IsThreadSafe() bool             { return true }
+func (op *dummy) GetDpx(idx int) ([][]byte, int) { return [][]byte{}, idx }
+func (op *dummy) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int) int {
+	return 0
+}
+
+func init() {
+	Register("dummy", func(data []byte, param param.Parameters) (Operation, error) {
+		d := dummy{label: "dummy"}
+		if label, err := jsonparser.GetString(data, "label"); err == nil {
+			d.label = label
+		}
+		minLength, err := jsonparser.GetInt(data, "min_length")
+		if err != nil && err != jsonparser.KeyPathNotFoundError {
+			return &d, err
+		}
+		d.minLength = minLength
+		return &d, nil
+	}, map[string]ParamSpec{"min_length": PIntRange(0, inf)})
+}
+
+func TestRegister(t *testing.T) {
+	for _, test := range []struct {
+		ops string
+		err string
+	}{
+		{`[{"name": "dummy", "min_length": 10}, {"name": "length", "min_length": 10, "if": {"label": "dummy"}}]`, ""},
+		{`[{"name": "dummy", "min_lenght": 10}]`, "[0].min_lenght: unknown parameter of dummy"},
+		{`[{"name": "dummy", "min_length": -1}]`, "[0].min_length: -1 out of range"},
+		{`[{"name": "dummy", "label": "length"}, {"name": "length"}]`, "[1]: duplicate label length (also used by [0])"},
+	} {
+		ops, err := ReadOps([]byte(test.ops), param.Parameters{})
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("%s: error %v, expected %q", test.ops, err, test.err)
+			continue
+		}
+		if err == nil {
+			if d, ok := ops[0].(*dummy); !ok || d.minLength != 10 {
+				t.Errorf("%s: dummy operation not built: %#v", test.ops, ops[0])
+			}
+		}
+	}
+}
+
+func TestSetAnnotations(t *testing.T) {
+	opsR1, err := ReadOps([]byte(`[{"name": "trim", "label": "adapter", "end": 3, "algo": "search", "sequence": "ACGTACGT"}, {"name": "trim", "label": "linker", "end": 3, "algo": "search", "sequence": "TTGACCAG"}, {"name": "clip", "end": 5, "length": 4, "if": {"label": "adapter", "in": ["trim_exact"]}}]`), param.Parameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opsR2, err := ReadOps([]byte(`[{"name": "length", "min_length": 10, "if": {"label": "linker", "read": 1}}]`), param.Parameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		opsR2    []Operation
+		annotate []bool
+	}{
+		{opsR2, []bool{true, true}},
+		{nil, []bool{true, false}},
+	} {
+		SetAnnotations(opsR1, test.opsR2)
+		for i, annotate := range test.annotate {
+			if opsR1[i].(*Trim).annotate != annotate {
+				t.Errorf("%s: annotate %t, expected %t", opsR1[i].Label(), !annotate, annotate)
+			}
+		}
+	}
+}
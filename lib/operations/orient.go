@@ -0,0 +1,320 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package operations
+
+import (
+	"fmt"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
+	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+	"git.sr.ht/~vejnar/ReadKnead/lib/param"
+	"git.sr.ht/~vejnar/ReadKnead/lib/trim"
+
+	"git.sr.ht/~vejnar/bktrim"
+	"github.com/buger/jsonparser"
+)
+
+const (
+	OrientForward = iota
+	OrientReverse
+	OrientUndetermined
+)
+
+var orientTypes = []string{"forward", "reverse", "undetermined"}
+
+type Orient struct {
+	name        string
+	label       string
+	sequences   [][]byte
+	end         int
+	algo        int
+	algoName    string
+	minSequence int
+	minScore    float32
+	position    int
+	nMode       bio.NMode
+	bkMatrices  []*bktrim.Matrix
+	bkParams    bkParams
+	method      string
+	keep        []bool
+	paired      bool
+	annotate    bool
+}
+
+func NewOrient(data []byte, param param.Parameters) (*Orient, error) {
+	o := Orient{name: "orient", annotate: true, paired: param.Paired}
+	// label
+	label, err := jsonparser.GetUnsafeString(data, "label")
+	if err != nil && err != jsonparser.KeyPathNotFoundError {
+		return &o, err
+	}
+	if label == "" {
+		o.label = o.name
+	} else {
+		o.label = label
+	}
+	// Marker sequence(s)
+	sequence, err := jsonparser.GetString(data, "sequence")
+	if err != nil && err != jsonparser.KeyPathNotFoundError {
+		return &o, err
+	} else if sequence != "" {
+		o.sequences = append(o.sequences, []byte(sequence))
+	}
+	err = nil
+	jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
+		if err == nil {
+			var s string
+			s, err = jsonparser.ParseString(value)
+			if err != nil {
+				return
+			}
+			o.sequences = append(o.sequences, []byte(s))
+		}
+	}, "sequences")
+	if err != nil {
+		return &o, err
+	}
+	if len(o.sequences) == 0 {
+		return &o, fmt.Errorf("marker sequence not found")
+	}
+	end, err := jsonparser.GetInt(data, "end")
+	if err == jsonparser.KeyPathNotFoundError {
+		o.end = 5
+	} else if err != nil {
+		return &o, err
+	} else {
+		o.end = int(end)
+	}
+	if !(o.end == 5 || o.end == 3) {
+		return &o, fmt.Errorf("unknown end: %d", o.end)
+	}
+	algoRaw, err := jsonparser.GetString(data, "algo")
+	if err == jsonparser.KeyPathNotFoundError {
+		algoRaw = "match"
+	} else if err != nil {
+		return &o, err
+	}
+	o.algoName = algoRaw
+	switch algoRaw {
+	case "match":
+		o.algo = TrimMatch
+	case "search":
+		o.algo = TrimSearch
+	case "bktrim":
+		o.algo = TrimBKTrim
+		epsilon, err := jsonparser.GetFloat(data, "epsilon")
+		if err == jsonparser.KeyPathNotFoundError {
+			epsilon = 0.1
+		} else if err != nil {
+			return &o, err
+		}
+		epsilonIndel, err := jsonparser.GetFloat(data, "epsilon_indel")
+		if err == jsonparser.KeyPathNotFoundError {
+			epsilonIndel = 0.03
+		} else if err != nil {
+			return &o, err
+		}
+		minOverlap, err := jsonparser.GetInt(data, "min_overlap")
+		if err == jsonparser.KeyPathNotFoundError {
+			minOverlap = 3
+		} else if err != nil {
+			return &o, err
+		}
+		o.bkParams = bkParams{epsilon, epsilonIndel, int(minOverlap)}
+		o.bkMatrices, err = trim.NewMatrixAdapter(o.sequences, o.end, epsilon, epsilonIndel, int(minOverlap), param.AsciiMin)
+		if err != nil {
+			return &o, err
+		}
+	default:
+		return &o, fmt.Errorf("unknown marker algorithm: %s", algoRaw)
+	}
+	minSequence, err := jsonparser.GetInt(data, "min_sequence")
+	if err == jsonparser.KeyPathNotFoundError {
+		o.minSequence = 0
+	} else if err != nil {
+		return &o, err
+	} else {
+		o.minSequence = int(minSequence)
+	}
+	minScore, err := jsonparser.GetFloat(data, "min_score")
+	if err == jsonparser.KeyPathNotFoundError {
+		o.minScore = 0.8
+	} else if err != nil {
+		return &o, err
+	} else {
+		o.minScore = float32(minScore)
+	}
+	position, err := jsonparser.GetInt(data, "position")
+	if err != nil && err != jsonparser.KeyPathNotFoundError {
+		return &o, err
+	} else {
+		o.position = int(position)
+	}
+	nMode, err := jsonparser.GetString(data, "n_mode")
+	if err == jsonparser.KeyPathNotFoundError {
+		o.nMode = bio.NMismatch
+	} else if err != nil {
+		return &o, err
+	} else {
+		o.nMode, err = bio.ParseNMode(nMode)
+		if err != nil {
+			return &o, err
+		}
+	}
+	method, err := jsonparser.GetString(data, "method")
+	if err == jsonparser.KeyPathNotFoundError {
+		if o.paired {
+			o.method = "swap"
+		} else {
+			o.method = "reverse_complement"
+		}
+	} else if err != nil {
+		return &o, err
+	} else {
+		o.method = method
+	}
+	if !(o.method == "swap" || o.method == "reverse_complement") {
+		return &o, fmt.Errorf("unknown orientation method: %s", o.method)
+	}
+	if o.method == "swap" && !o.paired {
+		return &o, fmt.Errorf("swap method requires paired-end reads")
+	}
+	// Marker found on read 2 is put on read 1 by exchanging mates only
+	if o.method == "reverse_complement" && o.paired {
+		return &o, fmt.Errorf("reverse_complement method requires single-end reads")
+	}
+	err = nil
+	o.keep = make([]bool, len(orientTypes))
+	found := false
+	jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
+		if err == nil {
+			var k string
+			k, err = jsonparser.ParseString(value)
+			if err != nil {
+				return
+			}
+			for it, t := range orientTypes {
+				if k == t {
+					o.keep[it] = true
+				}
+			}
+			found = true
+		}
+	}, "keep")
+	if !found {
+		for i := range o.keep {
+			o.keep[i] = true
+		}
+	}
+	if err != nil {
+		return &o, err
+	}
+	return &o, nil
+}
+
+func (op *Orient) Name() string {
+	return op.name
+}
+
+func (op *Orient) Label() string {
+	return op.label
+}
+
+func (op *Orient) IsThreadSafe() bool {
+	return true
+}
+
+func (op *Orient) SetAnnotate(annotate bool) {
+	op.annotate = annotate
+}
+
+func (op *Orient) GetDpx(idx int) ([][]byte, int) {
+	return [][]byte{}, idx
+}
+
+func (op *Orient) Describe() []Param {
+	params := []Param{
+		{"sequences", byteStrings(op.sequences)},
+		{"end", op.end},
+		{"algo", op.algoName},
+	}
+	switch op.algo {
+	case TrimMatch:
+		params = append(params, Param{"min_score", op.minScore}, Param{"min_sequence", op.minSequence}, Param{"position", op.position})
+	case TrimSearch:
+		params = append(params, Param{"min_score", op.minScore}, Param{"min_sequence", op.minSequence})
+	case TrimBKTrim:
+		params = append(params, op.bkParams.describe()...)
+	}
+	return append(params, []Param{
+		{"n_mode", op.nMode},
+		{"method", op.method},
+		{"keep", keepNames(op.keep, orientTypes)},
+	}...)
+}
+
+// Find marker in read
+func (op *Orient) find(read *fastq.Record, verboseLevel int) bool {
+	var trimType trim.TrimType
+	switch op.algo {
+	case TrimMatch:
+		trimType, _, _, _ = trim.TrimMatch(read, op.sequences, op.position, op.minSequence, op.minScore, op.end, op.nMode, trim.ActionNone, verboseLevel)
+	case TrimSearch:
+		trimType, _, _, _ = trim.TrimSearch(read, op.sequences, op.minSequence, op.minScore, op.end, op.nMode, trim.ActionNone, verboseLevel)
+	case TrimBKTrim:
+		trimType, _, _, _ = trim.TrimBKTrim(read, op.bkMatrices, op.minSequence, op.end, op.nMode, trim.ActionNone, verboseLevel)
+	}
+	return trimType == trim.TrimExactType || trimType == trim.TrimAlignType
+}
+
+func (op *Orient) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int) int {
+	if verboseLevel > 2 {
+		fmt.Printf("%s %s %s r%d\n%s\n%s\n", op.name, op.label, p.R1.Name, r, p.R1.Seq, p.R2.Seq)
+	}
+	orientType := OrientUndetermined
+	if op.find(&p.R1, verboseLevel) {
+		orientType = OrientForward
+	} else if op.paired {
+		if op.find(&p.R2, verboseLevel) {
+			orientType = OrientReverse
+		}
+	} else {
+		rc := fastq.Record{Name: p.R1.Name, Seq: bio.ReverseComplement(p.R1.Seq), Qual: bio.Reverse(p.R1.Qual)}
+		if op.find(&rc, verboseLevel) {
+			orientType = OrientReverse
+		}
+	}
+	if orientType == OrientReverse {
+		if op.method == "swap" {
+			// Read names kept with their file
+			p.R1.Seq, p.R2.Seq = p.R2.Seq, p.R1.Seq
+			p.R1.Qual, p.R2.Qual = p.R2.Qual, p.R1.Qual
+		} else {
+			p.R1.Seq, p.R1.Qual = bio.ReverseComplement(p.R1.Seq), bio.Reverse(p.R1.Qual)
+		}
+	}
+	if verboseLevel > 2 {
+		fmt.Printf("%s\n%s\n%s\n", orientTypes[orientType], p.R1.Seq, p.R2.Seq)
+	}
+	if op.annotate {
+		p.Annotate(r, op.label, orientTypes[orientType])
+	}
+	// Stats
+	if r == 1 {
+		ot.OpsR1[op.label][orientTypes[orientType]]++
+	} else {
+		ot.OpsR2[op.label][orientTypes[orientType]]++
+	}
+	if op.keep[orientType] {
+		return 0
+	} else {
+		p.Reject(op.label, orientTypes[orientType])
+		return 1
+	}
+}
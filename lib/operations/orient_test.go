@@ -0,0 +1,72 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package operations
+
+import (
+	"testing"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+	"git.sr.ht/~vejnar/ReadKnead/lib/param"
+)
+
+func TestOrient(t *testing.T) {
+	marker := "CTGCTGTACGGCCAAGGCG"
+	for _, test := range []struct {
+		ops     string
+		paired  bool
+		r1      fastq.Record
+		r2      fastq.Record
+		seqR1   string
+		seqR2   string
+		outcome string
+		err     string
+	}{
+		// Marker on read 2: mates exchanged, names kept
+		{`[{"name": "orient", "sequence": "` + marker + `"}]`, true,
+			fastq.Record{Name: []byte("read0 1:N:0:1"), Seq: []byte("GGACCAGTTAACCGG"), Qual: []byte("AAAAAAAAAAAAAAA")},
+			fastq.Record{Name: []byte("read0 2:N:0:1"), Seq: []byte(marker + "TTAGC"), Qual: []byte("BBBBBBBBBBBBBBBBBBBBBBBB")},
+			marker + "TTAGC", "GGACCAGTTAACCGG", "reverse", ""},
+		// Marker on read 1: pair unchanged
+		{`[{"name": "orient", "sequence": "` + marker + `"}]`, true,
+			fastq.Record{Name: []byte("read0 1:N:0:1"), Seq: []byte(marker + "TT"), Qual: []byte("AAAAAAAAAAAAAAAAAAAAA")},
+			fastq.Record{Name: []byte("read0 2:N:0:1"), Seq: []byte("GGACCAGTTAACCGG"), Qual: []byte("BBBBBBBBBBBBBBB")},
+			marker + "TT", "GGACCAGTTAACCGG", "forward", ""},
+		// Reverse complement of marker in single-end read
+		{`[{"name": "orient", "sequence": "` + marker + `"}]`, false,
+			fastq.Record{Name: []byte("read0"), Seq: []byte("TTCGCCTTGGCCGTACAGCAG"), Qual: []byte("AAAAAAAAAAAAAAAAAAAAB")},
+			fastq.Record{},
+			marker + "AA", "", "reverse", ""},
+		{`[{"name": "orient", "sequence": "` + marker + `", "method": "reverse_complement"}]`, true, fastq.Record{}, fastq.Record{}, "", "", "", "reverse_complement method requires single-end reads"},
+		{`[{"name": "orient", "sequence": "` + marker + `", "method": "swap"}]`, false, fastq.Record{}, fastq.Record{}, "", "", "", "swap method requires paired-end reads"},
+	} {
+		ops, err := ReadOps([]byte(test.ops), param.Parameters{Paired: test.paired})
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("%s: got error %v, expected %q", test.ops, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		ot := NewOpStat("", "", "", "", 0, 0, 0, test.paired, ops, nil)
+		p := fastq.ExtPair{Ok: true, R1: test.r1, R2: test.r2}
+		ops[0].Transform(&p, 1, ot, 0)
+		if string(p.R1.Seq) != test.seqR1 || string(p.R2.Seq) != test.seqR2 {
+			t.Errorf("%s: got %s and %s, expected %s and %s", test.ops, p.R1.Seq, p.R2.Seq, test.seqR1, test.seqR2)
+		}
+		if string(p.R1.Name) != string(test.r1.Name) || string(p.R2.Name) != string(test.r2.Name) {
+			t.Errorf("%s: names %s and %s changed", test.ops, p.R1.Name, p.R2.Name)
+		}
+		if len(p.R1.Qual) != len(p.R1.Seq) || len(p.R2.Qual) != len(p.R2.Seq) {
+			t.Errorf("%s: qualities not moved with sequences", test.ops)
+		}
+		if ot.OpsR1["orient"][test.outcome] != 1 {
+			t.Errorf("%s: stats %v, expected %s", test.ops, ot.OpsR1["orient"], test.outcome)
+		}
+	}
+}
@@ -10,6 +10,7 @@ package operations
 
 import (
 	"fmt"
+	"math"
 
 	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
 	"git.sr.ht/~vejnar/ReadKnead/lib/param"
@@ -18,11 +19,14 @@ import (
 )
 
 type Quality struct {
-	name       string
-	label      string
-	minQuality float32
-	function   string
-	param      param.Parameters
+	name              string
+	label             string
+	minQuality        float32
+	maxFraction       float32
+	maxExpectedErrors float32
+	function          string
+	pair              bool
+	param             param.Parameters
 }
 
 func NewQuality(data []byte, param param.Parameters) (*Quality, error) {
@@ -55,9 +59,36 @@ func NewQuality(data []byte, param param.Parameters) (*Quality, error) {
 	} else {
 		q.function = function
 	}
-	if !(q.function == "average") {
+	if !(q.function == "average" || q.function == "min" || q.function == "median" || q.function == "fraction_below" || q.function == "max_expected_errors") {
 		return &q, fmt.Errorf("unknown function: %s", q.function)
 	}
+	// maxFraction
+	maxFraction, err := jsonparser.GetFloat(data, "max_fraction")
+	if err == jsonparser.KeyPathNotFoundError {
+		q.maxFraction = 0.1
+	} else if err != nil {
+		return &q, err
+	} else {
+		q.maxFraction = float32(maxFraction)
+	}
+	// maxExpectedErrors
+	maxExpectedErrors, err := jsonparser.GetFloat(data, "max_expected_errors")
+	if err == jsonparser.KeyPathNotFoundError {
+		q.maxExpectedErrors = 2.
+	} else if err != nil {
+		return &q, err
+	} else {
+		q.maxExpectedErrors = float32(maxExpectedErrors)
+	}
+	// pair
+	pair, err := jsonparser.GetBoolean(data, "pair")
+	if err == jsonparser.KeyPathNotFoundError {
+		q.pair = false
+	} else if err != nil {
+		return &q, err
+	} else {
+		q.pair = pair
+	}
 	return &q, nil
 }
 
@@ -77,35 +108,124 @@ func (op *Quality) GetDpx(idx int) ([][]byte, int) {
 	return [][]byte{}, idx
 }
 
+func (op *Quality) Describe() []Param {
+	params := []Param{
+		{"function", op.function},
+		{"min_quality", op.minQuality},
+	}
+	switch op.function {
+	case "fraction_below":
+		params = append(params, Param{"max_fraction", op.maxFraction})
+	case "max_expected_errors":
+		params = append(params, Param{"max_expected_errors", op.maxExpectedErrors})
+	}
+	return append(params, Param{"pair", op.pair})
+}
+
 func (op *Quality) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int) int {
-	var read *fastq.Record
-	if r == 1 {
-		read = &p.R1
+	var quals [][]byte
+	if op.pair {
+		quals = [][]byte{p.R1.Qual, p.R2.Qual}
+		if verboseLevel > 2 {
+			fmt.Printf("%s %s pair\n%s\n%s\n%s\n%s\n", op.name, p.R1.Name, p.R1.Seq, p.R1.Qual, p.R2.Seq, p.R2.Qual)
+		}
 	} else {
-		read = &p.R2
+		var read *fastq.Record
+		if r == 1 {
+			read = &p.R1
+		} else {
+			read = &p.R2
+		}
+		quals = [][]byte{read.Qual}
+		if verboseLevel > 2 {
+			fmt.Printf("%s %s r%d\n%s\n%s\n", op.name, read.Name, r, read.Seq, read.Qual)
+		}
+	}
+	score, discard := op.evaluate(quals)
+	if verboseLevel > 3 {
+		fmt.Printf("> %s:%.2f discard:%t\n", op.function, score, discard)
+	}
+	if discard {
+		if r == 1 {
+			ot.OpsR1[op.label]["low_quality"]++
+		} else {
+			ot.OpsR2[op.label]["low_quality"]++
+		}
+		p.Reject(op.label, "low_quality")
+		return 1
+	} else {
+		return 0
+	}
+}
+
+// Compute read quality score with function and check if read(s) must be discarded
+func (op *Quality) evaluate(quals [][]byte) (float32, bool) {
+	var n int
+	for _, qual := range quals {
+		n += len(qual)
 	}
-	if verboseLevel > 2 {
-		fmt.Printf("%s %s r%d\n%s\n%s\n", op.name, read.Name, r, read.Seq, read.Qual)
+	if n == 0 {
+		return 0., false
 	}
-	if op.function == "average" {
+	switch op.function {
+	case "average":
 		sumQual := 0
-		for i := 0; i < len(read.Qual); i++ {
-			sumQual += int(read.Qual[i]) - op.param.AsciiMin
+		for _, qual := range quals {
+			for i := 0; i < len(qual); i++ {
+				sumQual += int(qual[i]) - op.param.AsciiMin
+			}
 		}
-		avgQual := float32(sumQual) / float32(len(read.Qual))
-		if verboseLevel > 3 {
-			fmt.Printf("> avg:%.2f discard:%t\n", avgQual, avgQual < op.minQuality)
+		avgQual := float32(sumQual) / float32(n)
+		return avgQual, avgQual < op.minQuality
+	case "min":
+		minQual := math.MaxInt
+		for _, qual := range quals {
+			for i := 0; i < len(qual); i++ {
+				minQual = min(minQual, int(qual[i])-op.param.AsciiMin)
+			}
 		}
-		if avgQual < op.minQuality {
-			if r == 1 {
-				ot.OpsR1[op.label]["low_quality"]++
-			} else {
-				ot.OpsR2[op.label]["low_quality"]++
+		return float32(minQual), float32(minQual) < op.minQuality
+	case "median":
+		var counts [256]int
+		for _, qual := range quals {
+			for i := 0; i < len(qual); i++ {
+				counts[qual[i]]++
+			}
+		}
+		// Median from quality counts (average of both middle values if even)
+		var medQual float32
+		lower, upper := (n-1)/2, n/2
+		cum := 0
+		for c := 0; c < len(counts); c++ {
+			if cum <= lower && lower < cum+counts[c] {
+				medQual += float32(c-op.param.AsciiMin) / 2.
+			}
+			if cum <= upper && upper < cum+counts[c] {
+				medQual += float32(c-op.param.AsciiMin) / 2.
+				break
+			}
+			cum += counts[c]
+		}
+		return medQual, medQual < op.minQuality
+	case "fraction_below":
+		nLow := 0
+		for _, qual := range quals {
+			for i := 0; i < len(qual); i++ {
+				if float32(int(qual[i])-op.param.AsciiMin) < op.minQuality {
+					nLow++
+				}
+			}
+		}
+		fraction := float32(nLow) / float32(n)
+		return fraction, fraction > op.maxFraction
+	case "max_expected_errors":
+		var expectedErrors float64
+		for _, qual := range quals {
+			for i := 0; i < len(qual); i++ {
+				expectedErrors += math.Pow(10., -float64(int(qual[i])-op.param.AsciiMin)/10.)
 			}
-			return 1
-		} else {
-			return 0
 		}
+		return float32(expectedErrors), float32(expectedErrors) > op.maxExpectedErrors
 	}
-	return 0
+	return 0., false
 }
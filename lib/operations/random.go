@@ -9,6 +9,10 @@
 package operations
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
 	"math/rand"
 
 	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
@@ -20,6 +24,16 @@ type Random struct {
 	name        string
 	label       string
 	probability float32
+	seed        int64
+	seeded      bool
+	count       uint64
+	perSample   bool
+	counting    bool
+	rng         *rand.Rand
+	totals      map[int]uint64
+	seen        map[int]uint64
+	selected    map[int]uint64
+	dpxNames    [][]byte
 }
 
 func NewRandom(data []byte) (*Random, error) {
@@ -42,6 +56,46 @@ func NewRandom(data []byte) (*Random, error) {
 	} else {
 		r.probability = float32(probability)
 	}
+	seed, err := jsonparser.GetInt(data, "seed")
+	if err == jsonparser.KeyPathNotFoundError {
+		r.seeded = false
+	} else if err != nil {
+		return &r, err
+	} else {
+		r.seed = seed
+		r.seeded = true
+	}
+	// Exact number of reads (or pairs)
+	count, err := jsonparser.GetInt(data, "count")
+	if err == jsonparser.KeyPathNotFoundError {
+		r.count = 0
+	} else if err != nil {
+		return &r, err
+	} else if count < 1 {
+		return &r, fmt.Errorf("count must be positive")
+	} else {
+		r.count = uint64(count)
+		r.counting = true
+		r.totals = make(map[int]uint64)
+		r.seen = make(map[int]uint64)
+		r.selected = make(map[int]uint64)
+		if r.seeded {
+			r.rng = rand.New(rand.NewSource(r.seed))
+		} else {
+			r.rng = rand.New(rand.NewSource(rand.Int63()))
+		}
+	}
+	perSample, err := jsonparser.GetBoolean(data, "per_sample")
+	if err == jsonparser.KeyPathNotFoundError {
+		r.perSample = false
+	} else if err != nil {
+		return &r, err
+	} else {
+		r.perSample = perSample
+	}
+	if r.perSample && r.count == 0 {
+		return &r, fmt.Errorf("per_sample requires count")
+	}
 	return &r, nil
 }
 
@@ -54,16 +108,120 @@ func (op *Random) Label() string {
 }
 
 func (op *Random) IsThreadSafe() bool {
-	return true
+	// Pairs are selected in order when count is set
+	return op.count == 0
+}
+
+// Deterministic reports if pairs are selected identically in every run (probability without seed
+// is not)
+func (op *Random) Deterministic() bool {
+	return op.count > 0 || op.seeded || op.probability >= 1
 }
 
 func (op *Random) GetDpx(idx int) ([][]byte, int) {
 	return [][]byte{}, idx
 }
 
+func (op *Random) Describe() []Param {
+	var params []Param
+	if op.count > 0 {
+		params = append(params, Param{"count", op.count}, Param{"per_sample", op.perSample})
+	} else {
+		params = append(params, Param{"probability", op.probability})
+	}
+	if op.seeded {
+		params = append(params, Param{"seed", op.seed})
+	}
+	return params
+}
+
 func (op *Random) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int) int {
-	if rand.Float32() > op.probability {
+	if op.count > 0 {
+		return op.sample(p, verboseLevel)
+	}
+	var v float32
+	if op.seeded {
+		v = op.hash(p.R1.Name)
+	} else {
+		v = rand.Float32()
+	}
+	if v > op.probability {
+		p.Reject(op.label, "not_sampled")
 		return 1
 	}
 	return 0
 }
+
+// Hash read name with seed to a number in [0,1). Comment and mate suffix (/1 or /2) are ignored
+// to select both reads of pair.
+func (op *Random) hash(name []byte) float32 {
+	if i := bytes.IndexAny(name, " \t"); i != -1 {
+		name = name[:i]
+	}
+	if bytes.HasSuffix(name, []byte("/1")) || bytes.HasSuffix(name, []byte("/2")) {
+		name = name[:len(name)-2]
+	}
+	h := fnv.New64a()
+	binary.Write(h, binary.LittleEndian, op.seed)
+	h.Write(name)
+	return float32(h.Sum64()>>40) / (1 << 24)
+}
+
+// Select exactly count pairs (per sample or overall) using selection sampling (Knuth's Algorithm S):
+// pairs are counted in a first pass, then selected in order in the second pass
+func (op *Random) sample(p *fastq.ExtPair, verboseLevel int) int {
+	wid := 0
+	if op.perSample {
+		wid = p.WID
+	}
+	if op.counting {
+		op.totals[wid]++
+		return 0
+	}
+	// More pairs than counted in the first pass
+	if op.seen[wid] >= op.totals[wid] {
+		p.Reject(op.label, "not_sampled")
+		return 1
+	}
+	remaining := op.totals[wid] - op.seen[wid]
+	op.seen[wid]++
+	if op.selected[wid] < op.count && uint64(op.rng.Int63n(int64(remaining))) < op.count-op.selected[wid] {
+		op.selected[wid]++
+		if verboseLevel > 2 {
+			fmt.Printf("%s %s %s selected\n", op.name, op.label, p.R1.Name)
+		}
+		return 0
+	}
+	p.Reject(op.label, "not_sampled")
+	return 1
+}
+
+// Counting reports if pairs are counted (first pass)
+func (op *Random) Counting() bool {
+	return op.counting
+}
+
+// StartSampling ends the first pass counting pairs
+func (op *Random) StartSampling(dpxNames [][]byte) {
+	op.counting = false
+	op.dpxNames = dpxNames
+}
+
+func (op *Random) Report() map[string]uint64 {
+	if op.count == 0 {
+		return nil
+	}
+	report := make(map[string]uint64)
+	if op.perSample {
+		for wid, n := range op.dpxNames {
+			report["requested:"+string(n)] = op.count
+			report["available:"+string(n)] = op.totals[wid]
+			report["achieved:"+string(n)] = op.selected[wid]
+		}
+	} else {
+		report["requested"] = op.count
+		report["available"] = op.totals[0]
+		report["achieved"] = op.selected[0]
+	}
+	return report
+}
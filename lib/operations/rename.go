@@ -9,8 +9,10 @@
 package operations
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 
 	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
@@ -19,14 +21,43 @@ import (
 	"github.com/buger/jsonparser"
 )
 
+const (
+	RenameText = iota
+	RenameSample
+	RenameLane
+	RenameID
+	RenameBarcodes
+)
+
+const (
+	NumberingOutput = iota
+	NumberingInput
+)
+
+var numberingTypes = []string{"output", "input"}
+
+var renameFieldRe = regexp.MustCompile(`\{(sample|lane|id|barcodes)(?::([0-9]*[dxX]))?\}`)
+
+// Part of new read name: text or field
+type renameField struct {
+	kind   int
+	text   []byte
+	format string
+}
+
 type Rename struct {
 	name         string
 	label        string
-	newName      []byte
+	template     string
+	fields       []renameField
 	base36       bool
-	keepBarcode  bool
 	mergeBarcode bool
 	allReads     bool
+	keepOriginal bool
+	numbering    int
+	perSample    bool
+	dpxNames     [][]byte
+	counts       []uint64
 }
 
 func NewRename(data []byte) (*Rename, error) {
@@ -41,15 +72,6 @@ func NewRename(data []byte) (*Rename, error) {
 	} else {
 		r.label = label
 	}
-	newName, err := jsonparser.GetUnsafeString(data, "new_name")
-	if err != nil {
-		if errors.Is(err, jsonparser.KeyPathNotFoundError) {
-			return &r, fmt.Errorf("%w: %v", err, "new_name")
-		}
-		return &r, err
-	} else {
-		r.newName = []byte(newName)
-	}
 	base36, err := jsonparser.GetBoolean(data, "base36")
 	if err == jsonparser.KeyPathNotFoundError {
 		r.base36 = false
@@ -59,12 +81,30 @@ func NewRename(data []byte) (*Rename, error) {
 		r.base36 = base36
 	}
 	keepBarcode, err := jsonparser.GetBoolean(data, "keep_barcode")
+	if err != nil && err != jsonparser.KeyPathNotFoundError {
+		return &r, err
+	}
+	// Template or new name followed by read number
+	template, err := jsonparser.GetString(data, "template")
 	if err == jsonparser.KeyPathNotFoundError {
-		r.keepBarcode = false
+		newName, err := jsonparser.GetString(data, "new_name")
+		if err != nil {
+			if errors.Is(err, jsonparser.KeyPathNotFoundError) {
+				return &r, fmt.Errorf("%w: %v", err, "new_name or template")
+			}
+			return &r, err
+		}
+		r.fields = []renameField{{kind: RenameText, text: []byte(newName)}, {kind: RenameID}}
+		r.template = newName + "{id}"
+		if keepBarcode {
+			r.fields = append(r.fields, renameField{kind: RenameBarcodes})
+			r.template += "{barcodes}"
+		}
 	} else if err != nil {
 		return &r, err
 	} else {
-		r.keepBarcode = keepBarcode
+		r.fields = parseTemplate(template)
+		r.template = template
 	}
 	mergeBarcode, err := jsonparser.GetBoolean(data, "merge_barcode")
 	if err == jsonparser.KeyPathNotFoundError {
@@ -82,9 +122,76 @@ func NewRename(data []byte) (*Rename, error) {
 	} else {
 		r.allReads = allReads
 	}
+	keepOriginal, err := jsonparser.GetBoolean(data, "keep_original")
+	if err == jsonparser.KeyPathNotFoundError {
+		r.keepOriginal = false
+	} else if err != nil {
+		return &r, err
+	} else {
+		r.keepOriginal = keepOriginal
+	}
+	numbering, err := jsonparser.GetString(data, "numbering")
+	if err == jsonparser.KeyPathNotFoundError {
+		r.numbering = NumberingOutput
+	} else if err != nil {
+		return &r, err
+	} else {
+		r.numbering = -1
+		for it, t := range numberingTypes {
+			if numbering == t {
+				r.numbering = it
+			}
+		}
+		if r.numbering == -1 {
+			return &r, fmt.Errorf("unknown numbering: %s", numbering)
+		}
+	}
+	perSample, err := jsonparser.GetBoolean(data, "per_sample")
+	if err == jsonparser.KeyPathNotFoundError {
+		r.perSample = false
+	} else if err != nil {
+		return &r, err
+	} else {
+		r.perSample = perSample
+	}
+	if r.perSample && r.numbering == NumberingInput {
+		return &r, fmt.Errorf("per_sample requires output numbering")
+	}
+	r.counts = make([]uint64, 1)
 	return &r, nil
 }
 
+// Split template into text and fields
+func parseTemplate(template string) []renameField {
+	var fields []renameField
+	var last int
+	for _, m := range renameFieldRe.FindAllStringSubmatchIndex(template, -1) {
+		if m[0] > last {
+			fields = append(fields, renameField{kind: RenameText, text: []byte(template[last:m[0]])})
+		}
+		f := renameField{}
+		switch template[m[2]:m[3]] {
+		case "sample":
+			f.kind = RenameSample
+		case "lane":
+			f.kind = RenameLane
+		case "id":
+			f.kind = RenameID
+			if m[4] != -1 {
+				f.format = "%" + template[m[4]:m[5]]
+			}
+		case "barcodes":
+			f.kind = RenameBarcodes
+		}
+		fields = append(fields, f)
+		last = m[1]
+	}
+	if last < len(template) {
+		fields = append(fields, renameField{kind: RenameText, text: []byte(template[last:])})
+	}
+	return fields
+}
+
 func (op *Rename) Name() string {
 	return op.name
 }
@@ -94,42 +201,115 @@ func (op *Rename) Label() string {
 }
 
 func (op *Rename) IsThreadSafe() bool {
-	return false
+	// Reads numbered by input position can be renamed in any order
+	return op.numbering == NumberingInput
+}
+
+// OnPair reports if both reads are renamed
+func (op *Rename) OnPair() bool {
+	return op.allReads
 }
 
 func (op *Rename) GetDpx(idx int) ([][]byte, int) {
 	return [][]byte{}, idx
 }
 
+func (op *Rename) Describe() []Param {
+	return []Param{
+		{"template", op.template},
+		{"base36", op.base36},
+		{"merge_barcode", op.mergeBarcode},
+		{"all_reads", op.allReads},
+		{"keep_original", op.keepOriginal},
+		{"numbering", numberingTypes[op.numbering]},
+		{"per_sample", op.perSample},
+	}
+}
+
+// SetDpxNames records the names of demultiplexed samples
+func (op *Rename) SetDpxNames(dpxNames [][]byte) {
+	op.dpxNames = dpxNames
+	if op.perSample {
+		op.counts = make([]uint64, len(dpxNames))
+	}
+}
+
 func (op *Rename) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int) int {
-	var barcode []byte
-	if r == 1 || op.allReads {
-		if op.keepBarcode {
-			barcode = getBarcode(p.R1.Name)
-			if op.mergeBarcode {
-				barcode = mergeBarcode(barcode)
-			}
-		}
-		if op.base36 {
-			p.R1.Name = joinThree(op.newName, []byte(strconv.FormatUint(p.ID, 36)), barcode)
-		} else {
-			p.R1.Name = joinThree(op.newName, []byte(strconv.FormatUint(p.ID, 10)), barcode)
+	var id uint64
+	if op.numbering == NumberingInput {
+		id = p.ID + 1
+	} else {
+		// Reads are numbered in order (per sample)
+		var ic int
+		if op.perSample {
+			ic = p.WID
 		}
+		op.counts[ic]++
+		id = op.counts[ic]
+	}
+	if r == 1 || op.allReads {
+		p.R1.Name = op.newName(p.R1.Name, id, p.WID)
 	}
 	if r == 2 || op.allReads {
-		if op.keepBarcode {
-			barcode = getBarcode(p.R2.Name)
-			if op.mergeBarcode {
+		p.R2.Name = op.newName(p.R2.Name, id, p.WID)
+	}
+	return 0
+}
+
+// Build new read name from template
+func (op *Rename) newName(name []byte, id uint64, wid int) []byte {
+	var n []byte
+	for _, f := range op.fields {
+		switch f.kind {
+		case RenameText:
+			n = append(n, f.text...)
+		case RenameSample:
+			if wid < len(op.dpxNames) {
+				n = append(n, op.dpxNames[wid]...)
+			}
+		case RenameLane:
+			n = append(n, getLane(name)...)
+		case RenameID:
+			if f.format != "" {
+				n = fmt.Appendf(n, f.format, id)
+			} else if op.base36 {
+				n = strconv.AppendUint(n, id, 36)
+			} else {
+				n = strconv.AppendUint(n, id, 10)
+			}
+		case RenameBarcodes:
+			barcode := getBarcode(name)
+			if op.mergeBarcode && len(barcode) > 0 {
 				barcode = mergeBarcode(barcode)
 			}
-		}
-		if op.base36 {
-			p.R2.Name = joinThree(op.newName, []byte(strconv.FormatUint(p.ID, 36)), barcode)
-		} else {
-			p.R2.Name = joinThree(op.newName, []byte(strconv.FormatUint(p.ID, 10)), barcode)
+			n = append(n, barcode...)
 		}
 	}
-	return 0
+	// Original read ID in comment
+	if op.keepOriginal {
+		n = append(n, ' ')
+		n = append(n, getReadID(name)...)
+	}
+	return n
+}
+
+// Read ID: name without comment
+func getReadID(name []byte) []byte {
+	if i := bytes.IndexByte(name, ' '); i != -1 {
+		return name[:i]
+	}
+	return name
+}
+
+// Lane in Illumina read ID (instrument:run:flowcell:lane:tile:x:y or instrument:lane:tile:x:y#index/read)
+func getLane(name []byte) []byte {
+	fields := bytes.Split(getReadID(name), []byte(":"))
+	if len(fields) >= 7 {
+		return fields[3]
+	} else if len(fields) == 5 {
+		return fields[1]
+	}
+	return []byte{}
 }
 
 func getBarcode(name []byte) []byte {
@@ -0,0 +1,413 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package operations
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
+	"git.sr.ht/~vejnar/ReadKnead/lib/trim"
+
+	"github.com/buger/jsonparser"
+)
+
+const (
+	ParamString = iota
+	ParamInt
+	ParamFloat
+	ParamBool
+	ParamStrings
+	ParamObject
+	ParamObjects
+	ParamPipelines
+)
+
+var paramTypes = []string{"string", "integer", "float", "boolean", "array of strings", "object", "array of objects", "object of operations"}
+
+// ParamSpec is the schema of a parameter (see PString, PInt, etc.)
+type ParamSpec struct {
+	kind   int
+	min    float64
+	max    float64
+	values []string             // Allowed values
+	fields map[string]ParamSpec // Fields of object(s)
+}
+
+var inf = math.Inf(1)
+
+func PString() ParamSpec {
+	return ParamSpec{kind: ParamString}
+}
+
+// String among values
+func PEnum(values ...string) ParamSpec {
+	return ParamSpec{kind: ParamString, values: values}
+}
+
+func PBool() ParamSpec {
+	return ParamSpec{kind: ParamBool}
+}
+
+func PInt() ParamSpec {
+	return PIntRange(-inf, inf)
+}
+
+func PIntRange(min, max float64) ParamSpec {
+	return ParamSpec{kind: ParamInt, min: min, max: max}
+}
+
+func PFloat() ParamSpec {
+	return PFloatRange(-inf, inf)
+}
+
+func PFloatRange(min, max float64) ParamSpec {
+	return ParamSpec{kind: ParamFloat, min: min, max: max}
+}
+
+// End of read: 5 or 3
+func PEnd() ParamSpec {
+	return ParamSpec{kind: ParamInt, min: 3, max: 5, values: []string{"5", "3"}}
+}
+
+func PStrings(values ...string) ParamSpec {
+	return ParamSpec{kind: ParamStrings, values: values}
+}
+
+func PObject(kind int, fields map[string]ParamSpec) ParamSpec {
+	return ParamSpec{kind: kind, fields: fields}
+}
+
+// Parameters of all operations
+var commonParams = map[string]ParamSpec{
+	"name":  PString(),
+	"label": PString(),
+	"if": PObject(ParamObject, map[string]ParamSpec{
+		"label":      PString(),
+		"read":       PIntRange(0, 2),
+		"in":         PStrings(),
+		"not_in":     PStrings(),
+		"min_length": PIntRange(-1, inf),
+		"max_length": PIntRange(-1, inf),
+	}),
+	"on_fail": PEnum("pair", "mate"),
+}
+
+// Parameters per operation (built-in or registered with a schema)
+var opParams = map[string]map[string]ParamSpec{
+	"amplicon": {
+		"primers": PObject(ParamObjects, map[string]ParamSpec{
+			"name":    PString(),
+			"forward": PString(),
+			"reverse": PString(),
+		}),
+		"min_score":   PFloatRange(0, 1),
+		"n_mode":      PEnum(nModeNames()...),
+		"action":      PEnum(actionNames()...),
+		"keep":        PStrings(ampliconTypes...),
+		"demultiplex": PBool(),
+	},
+	"clip": {
+		"length":        PIntRange(0, inf),
+		"end":           PEnd(),
+		"add_clipped":   PBool(),
+		"add_separator": PBool(),
+		"action":        PEnum(actionNames()...),
+	},
+	"complexity": {
+		"function":    PEnum("dust", "entropy", "dust_entropy"),
+		"window":      PIntRange(1, inf),
+		"step":        PIntRange(1, inf),
+		"max_dust":    PFloatRange(0, 100),
+		"min_entropy": PFloatRange(0, 100),
+		"pair":        PBool(),
+	},
+	"demultiplex": {
+		"barcodes":      PStrings(),
+		"end":           PEnd(),
+		"barcode_idx":   PIntRange(0, inf),
+		"source":        PEnum(dpxSources...),
+		"max_mismatch":  PIntRange(0, inf),
+		"length_ligand": PIntRange(0, inf),
+		"n_mode":        PEnum(nModeNames()...),
+		"pipelines":     PObject(ParamPipelines, nil),
+	},
+	"length": {
+		"min_length": PIntRange(-1, inf),
+		"max_length": PIntRange(-1, inf),
+	},
+	"orient": {
+		"sequence":      PString(),
+		"sequences":     PStrings(),
+		"end":           PEnd(),
+		"algo":          PEnum("match", "search", "bktrim"),
+		"min_score":     PFloatRange(0, 1),
+		"min_sequence":  PIntRange(0, inf),
+		"position":      PIntRange(0, inf),
+		"n_mode":        PEnum(nModeNames()...),
+		"epsilon":       PFloatRange(0, 1),
+		"epsilon_indel": PFloatRange(0, 1),
+		"min_overlap":   PIntRange(0, inf),
+		"method":        PEnum("swap", "reverse_complement"),
+		"keep":          PStrings(orientTypes...),
+	},
+	"quality": {
+		"min_quality":         PFloatRange(0, inf),
+		"function":            PEnum("average", "min", "median", "fraction_below", "max_expected_errors"),
+		"max_fraction":        PFloatRange(0, 1),
+		"max_expected_errors": PFloatRange(0, inf),
+		"pair":                PBool(),
+	},
+	"random": {
+		"probability": PFloatRange(0, 1),
+		"seed":        PInt(),
+		"count":       PIntRange(0, inf),
+		"per_sample":  PBool(),
+	},
+	"rename": {
+		"new_name":      PString(),
+		"template":      PString(),
+		"base36":        PBool(),
+		"keep_barcode":  PBool(),
+		"merge_barcode": PBool(),
+		"all_reads":     PBool(),
+		"keep_original": PBool(),
+		"numbering":     PEnum(numberingTypes...),
+		"per_sample":    PBool(),
+	},
+	"screen": {
+		"references": PObject(ParamObjects, map[string]ParamSpec{
+			"name": PString(),
+			"path": PString(),
+		}),
+		"k":            PIntRange(1, 32),
+		"min_fraction": PFloatRange(0, 1),
+		"keep":         PStrings(screenTypes...),
+		"demultiplex":  PBool(),
+	},
+	"trim": {
+		"sequence":             PString(),
+		"sequences":            PStrings(),
+		"sequence_paired":      PString(),
+		"sequences_paired":     PStrings(),
+		"sequence_linked":      PString(),
+		"sequences_linked":     PStrings(),
+		"mode":                 PEnum("end", "linked", "anywhere"),
+		"linked_required":      PEnum("both", "5"),
+		"times":                PIntRange(1, inf),
+		"auto_sample":          PIntRange(1, inf),
+		"auto_unknown":         PBool(),
+		"add_trimmed":          PBool(),
+		"add_trimmed_ref":      PBool(),
+		"add_separator":        PBool(),
+		"action":               PEnum(actionNames()...),
+		"apply_trim_seq":       PBool(),
+		"algo":                 PEnum("bktrim", "bktrim_paired", "align", "search", "match", "trimqual"),
+		"end":                  PEnd(),
+		"min_sequence":         PIntRange(0, inf),
+		"min_score":            PFloat(),
+		"alignment":            PEnum("free_end", "global", "semiglobal"),
+		"match_score":          PInt(),
+		"mismatch_score":       PInt(),
+		"gap_open":             PInt(),
+		"gap_extend":           PInt(),
+		"position":             PIntRange(0, inf),
+		"n_mode":               PEnum(nModeNames()...),
+		"epsilon":              PFloatRange(0, 1),
+		"epsilon_indel":        PFloatRange(0, 1),
+		"min_overlap":          PIntRange(0, inf),
+		"window":               PIntRange(1, inf),
+		"unqualified_prop_max": PFloatRange(0, 1),
+		"min_quality":          PIntRange(0, inf),
+		"keep":                 PStrings(trimTypeNames()...),
+		"length_ligand":        PIntRange(0, inf),
+		"add_ligand":           PBool(),
+		"add_ligand_separator": PBool(),
+	},
+}
+
+func actionNames() []string {
+	var names []string
+	for _, a := range trim.Actions {
+		names = append(names, a.String())
+	}
+	return names
+}
+
+func nModeNames() []string {
+	var names []string
+	for _, m := range []bio.NMode{bio.NMismatch, bio.NMatch, bio.NHalf} {
+		names = append(names, m.String())
+	}
+	return names
+}
+
+func trimTypeNames() []string {
+	var names []string
+	for _, tt := range trim.TrimTypes {
+		names = append(names, tt.String())
+	}
+	return names
+}
+
+// Check operations in data: unknown parameters, types, ranges and duplicate labels.
+// Errors report the JSON path of the parameter (e.g. [2].min_length).
+func validateOps(data []byte, path string) error {
+	if _, dataType, _, err := jsonparser.Get(data); err != nil || dataType != jsonparser.Array {
+		if path == "" {
+			return fmt.Errorf("operations must be an array")
+		}
+		return fmt.Errorf("%s: operations must be an array", path)
+	}
+	var err error
+	var i int
+	labels := make(map[string]string)
+	jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
+		if err == nil {
+			opPath := fmt.Sprintf("%s[%d]", path, i)
+			if dataType != jsonparser.Object {
+				err = fmt.Errorf("%s: operation must be an object", opPath)
+				return
+			}
+			opName, err2 := jsonparser.GetString(value, "name")
+			if err2 != nil {
+				err = fmt.Errorf("%s: operation \"name\" missing", opPath)
+				return
+			}
+			if _, ok := registry[opName]; !ok {
+				err = fmt.Errorf("%s: unknown operation: %s", opPath, opName)
+				return
+			}
+			// Parameters of registered operations without schema are checked by their constructor
+			params, checked := opParams[opName]
+			err = jsonparser.ObjectEach(value, func(key []byte, v []byte, vType jsonparser.ValueType, offset int) error {
+				spec, ok := commonParams[string(key)]
+				if !ok {
+					spec, ok = params[string(key)]
+				}
+				if !ok {
+					if !checked {
+						return nil
+					}
+					return fmt.Errorf("%s.%s: unknown parameter of %s", opPath, key, opName)
+				}
+				return validateParam(v, vType, spec, opPath+"."+string(key))
+			})
+			if err != nil {
+				return
+			}
+			// Labels are unique
+			label, _ := jsonparser.GetString(value, "label")
+			if label == "" {
+				label = opName
+			}
+			if prevPath, ok := labels[label]; ok {
+				err = fmt.Errorf("%s: duplicate label %s (also used by %s)", opPath, label, prevPath)
+				return
+			}
+			labels[label] = opPath
+		}
+		i++
+	})
+	return err
+}
+
+// Check value v of type vType matches spec
+func validateParam(v []byte, vType jsonparser.ValueType, spec ParamSpec, path string) error {
+	var err error
+	switch spec.kind {
+	case ParamString:
+		if vType != jsonparser.String {
+			return fmt.Errorf("%s: %s expected", path, paramTypes[spec.kind])
+		}
+	case ParamInt:
+		var n int64
+		if vType == jsonparser.Number {
+			n, err = strconv.ParseInt(string(v), 10, 64)
+		}
+		if vType != jsonparser.Number || err != nil {
+			return fmt.Errorf("%s: %s expected", path, paramTypes[spec.kind])
+		}
+		if float64(n) < spec.min || float64(n) > spec.max {
+			return fmt.Errorf("%s: %d out of range", path, n)
+		}
+	case ParamFloat:
+		var f float64
+		if vType == jsonparser.Number {
+			f, err = strconv.ParseFloat(string(v), 64)
+		}
+		if vType != jsonparser.Number || err != nil {
+			return fmt.Errorf("%s: %s expected", path, paramTypes[spec.kind])
+		}
+		if f < spec.min || f > spec.max {
+			return fmt.Errorf("%s: %s out of range", path, v)
+		}
+	case ParamBool:
+		if vType != jsonparser.Boolean {
+			return fmt.Errorf("%s: %s expected", path, paramTypes[spec.kind])
+		}
+	case ParamStrings:
+		if vType != jsonparser.Array {
+			return fmt.Errorf("%s: %s expected", path, paramTypes[spec.kind])
+		}
+		var i int
+		jsonparser.ArrayEach(v, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
+			if err == nil {
+				err = validateParam(value, dataType, ParamSpec{kind: ParamString, values: spec.values}, fmt.Sprintf("%s[%d]", path, i))
+			}
+			i++
+		})
+		return err
+	case ParamObject:
+		if vType != jsonparser.Object {
+			return fmt.Errorf("%s: %s expected", path, paramTypes[spec.kind])
+		}
+		return jsonparser.ObjectEach(v, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
+			fspec, ok := spec.fields[string(key)]
+			if !ok {
+				return fmt.Errorf("%s.%s: unknown parameter", path, key)
+			}
+			return validateParam(value, dataType, fspec, path+"."+string(key))
+		})
+	case ParamObjects:
+		if vType != jsonparser.Array {
+			return fmt.Errorf("%s: %s expected", path, paramTypes[spec.kind])
+		}
+		var i int
+		jsonparser.ArrayEach(v, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
+			if err == nil {
+				err = validateParam(value, dataType, PObject(ParamObject, spec.fields), fmt.Sprintf("%s[%d]", path, i))
+			}
+			i++
+		})
+		return err
+	case ParamPipelines:
+		if vType != jsonparser.Object {
+			return fmt.Errorf("%s: %s expected", path, paramTypes[spec.kind])
+		}
+		return jsonparser.ObjectEach(v, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
+			return validateOps(value, path+"."+string(key))
+		})
+	}
+	// Allowed values
+	if len(spec.values) > 0 {
+		found := false
+		for _, a := range spec.values {
+			if string(v) == a {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: unknown value %s (%s)", path, v, strings.Join(spec.values, ", "))
+		}
+	}
+	return nil
+}
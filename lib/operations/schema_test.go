@@ -0,0 +1,47 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package operations
+
+import (
+	"testing"
+)
+
+func TestValidateOps(t *testing.T) {
+	for _, test := range []struct {
+		ops string
+		err string
+	}{
+		{`[{"name": "length", "min_length": 10}, {"name": "length", "label": "max_length", "max_length": 50}]`, ""},
+		{`{"name": "length"}`, "operations must be an array"},
+		{`[{"min_length": 10}]`, `[0]: operation "name" missing`},
+		{`[{"name": "lenght"}]`, "[0]: unknown operation: lenght"},
+		{`[{"name": "length", "min_lenght": 10}]`, "[0].min_lenght: unknown parameter of length"},
+		{`[{"name": "length", "min_length": "10"}]`, "[0].min_length: integer expected"},
+		{`[{"name": "length", "min_length": 1.5}]`, "[0].min_length: integer expected"},
+		{`[{"name": "clip", "end": 6, "length": 2}]`, "[0].end: 6 out of range"},
+		{`[{"name": "clip", "end": 4, "length": 2}]`, "[0].end: unknown value 4 (5, 3)"},
+		{`[{"name": "random", "probability": 1.5}]`, "[0].probability: 1.5 out of range"},
+		{`[{"name": "length", "min_length": 10}, {"name": "length", "max_length": 50}]`, "[1]: duplicate label length (also used by [0])"},
+		{`[{"name": "trim", "end": 3, "sequence": "ACGT", "algo": "bktrm"}]`, "[0].algo: unknown value bktrm (bktrim, bktrim_paired, align, search, match, trimqual)"},
+		{`[{"name": "trim", "end": 3, "sequence": "ACGT", "n_mode": "none"}]`, "[0].n_mode: unknown value none (mismatch, match, half)"},
+		{`[{"name": "trim", "end": 3, "sequence": "ACGT", "keep": ["trim_exct"]}]`, "[0].keep[0]: unknown value trim_exct (no_trim, trim_exact, trim_align, trim_too_short)"},
+		{`[{"name": "quality", "function": "mean"}]`, "[0].function: unknown value mean (average, min, median, fraction_below, max_expected_errors)"},
+		{`[{"name": "demultiplex", "end": 5, "barcodes": ["ACGT"], "source": "index"}]`, "[0].source: unknown value index (sequence, name, header)"},
+		{`[{"name": "rename", "new_name": "read", "numbering": "first"}]`, "[0].numbering: unknown value first (output, input)"},
+		{`[{"name": "length", "min_length": 10, "on_fail": "read"}]`, "[0].on_fail: unknown value read (pair, mate)"},
+		{`[{"name": "length", "min_length": 10, "if": {"labl": "trim"}}]`, "[0].if.labl: unknown parameter"},
+		{`[{"name": "screen", "references": [{"name": "phix", "file": "phix.fa"}]}]`, "[0].references[0].file: unknown parameter"},
+		{`[{"name": "demultiplex", "end": 5, "barcodes": ["ACGT"], "pipelines": {"ACGT": [{"name": "length", "min_length": "x"}]}}]`, "[0].pipelines.ACGT[0].min_length: integer expected"},
+	} {
+		err := validateOps([]byte(test.ops), "")
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("%s: got error %v, expected %q", test.ops, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package operations
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
+	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+	"git.sr.ht/~vejnar/ReadKnead/lib/param"
+
+	"github.com/buger/jsonparser"
+)
+
+const (
+	ScreenClean = iota
+	ScreenHit
+)
+
+var screenTypes = []string{"clean", "hit"}
+
+type Screen struct {
+	Names        [][]byte
+	ReferencesID []int
+	name         string
+	label        string
+	index        *bio.KmerIndex
+	minFraction  float64
+	keep         []bool
+	demultiplex  bool
+	cleanID      int
+	annotate     bool
+}
+
+func NewScreen(data []byte, param param.Parameters) (*Screen, error) {
+	s := Screen{name: "screen", annotate: true}
+	// label
+	label, err := jsonparser.GetUnsafeString(data, "label")
+	if err != nil && err != jsonparser.KeyPathNotFoundError {
+		return &s, err
+	}
+	if label == "" {
+		s.label = s.name
+	} else {
+		s.label = label
+	}
+	k, err := jsonparser.GetInt(data, "k")
+	if err == jsonparser.KeyPathNotFoundError {
+		k = 27
+	} else if err != nil {
+		return &s, err
+	}
+	if k < 1 || k > 32 {
+		return &s, fmt.Errorf("k must be between 1 and 32")
+	}
+	s.index = bio.NewKmerIndex(int(k))
+	// references
+	err = nil
+	jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
+		if err == nil {
+			if len(s.Names) == bio.MaxKmerRefs {
+				err = fmt.Errorf("more than %d references", bio.MaxKmerRefs)
+				return
+			}
+			var name, path string
+			path, err = jsonparser.GetString(value, "path")
+			if err != nil {
+				err = fmt.Errorf("%w: %v", err, "reference path")
+				return
+			}
+			name, err = jsonparser.GetString(value, "name")
+			if err == jsonparser.KeyPathNotFoundError {
+				name = strings.TrimSuffix(filepath.Base(path), ".gz")
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			} else if err != nil {
+				return
+			}
+			if !filepath.IsAbs(path) && param.Dir != "" {
+				path = filepath.Join(param.Dir, path)
+			}
+			var seqs [][]byte
+			seqs, err = bio.ReadFasta(path)
+			if err != nil {
+				return
+			}
+			for _, seq := range seqs {
+				s.index.Add(seq, len(s.Names))
+			}
+			s.Names = append(s.Names, []byte(name))
+		}
+	}, "references")
+	if err != nil {
+		return &s, err
+	}
+	if len(s.Names) == 0 {
+		return &s, fmt.Errorf("references not found")
+	}
+	minFraction, err := jsonparser.GetFloat(data, "min_fraction")
+	if err == jsonparser.KeyPathNotFoundError {
+		s.minFraction = 0.5
+	} else if err != nil {
+		return &s, err
+	} else {
+		s.minFraction = minFraction
+	}
+	err = nil
+	s.keep = make([]bool, len(screenTypes))
+	found := false
+	jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
+		if err == nil {
+			var k string
+			k, err = jsonparser.ParseString(value)
+			if err != nil {
+				return
+			}
+			for it, t := range screenTypes {
+				if k == t {
+					s.keep[it] = true
+				}
+			}
+			found = true
+		}
+	}, "keep")
+	if !found {
+		s.keep[ScreenClean] = true
+	}
+	if err != nil {
+		return &s, err
+	}
+	demultiplex, err := jsonparser.GetBoolean(data, "demultiplex")
+	if err == jsonparser.KeyPathNotFoundError {
+		s.demultiplex = false
+	} else if err != nil {
+		return &s, err
+	} else {
+		s.demultiplex = demultiplex
+	}
+	return &s, nil
+}
+
+func (op *Screen) Name() string {
+	return op.name
+}
+
+func (op *Screen) Label() string {
+	return op.label
+}
+
+func (op *Screen) IsThreadSafe() bool {
+	return true
+}
+
+func (op *Screen) SetAnnotate(annotate bool) {
+	op.annotate = annotate
+}
+
+func (op *Screen) GetDpx(idx int) ([][]byte, int) {
+	if !op.demultiplex {
+		return [][]byte{}, idx
+	}
+	var names [][]byte
+	names = append(names, []byte("clean"))
+	op.cleanID = idx
+	idx++
+	op.ReferencesID = op.ReferencesID[:0]
+	for _, n := range op.Names {
+		names = append(names, n)
+		op.ReferencesID = append(op.ReferencesID, idx)
+		idx++
+	}
+	return names, idx
+}
+
+func (op *Screen) Describe() []Param {
+	return []Param{
+		{"references", byteStrings(op.Names)},
+		{"k", op.index.K},
+		{"kmers", op.index.Len()},
+		{"min_fraction", op.minFraction},
+		{"keep", keepNames(op.keep, screenTypes)},
+		{"demultiplex", op.demultiplex},
+	}
+}
+
+func (op *Screen) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int) int {
+	seq := p.R1.Seq
+	if r == 2 {
+		seq = p.R2.Seq
+	}
+	if verboseLevel > 2 {
+		fmt.Printf("%s %s r%d\n%s\n", op.name, op.label, r, seq)
+	}
+	// Reference sharing most k-mers with read
+	hits := make([]int, len(op.Names))
+	n := op.index.Hits(seq, hits)
+	best := 0
+	for i := range hits {
+		if hits[i] > hits[best] {
+			best = i
+		}
+	}
+	screenType := ScreenClean
+	fraction := 0.
+	if n > 0 {
+		fraction = float64(hits[best]) / float64(n)
+	}
+	if hits[best] > 0 && fraction >= op.minFraction {
+		screenType = ScreenHit
+	}
+	outcome := screenTypes[screenType]
+	if screenType == ScreenHit {
+		outcome = string(op.Names[best])
+	}
+	if verboseLevel > 2 {
+		fmt.Printf("> %s fraction:%.2f\n", outcome, fraction)
+	}
+	// Stats
+	stats := ot.OpsR1[op.label]
+	if r == 2 {
+		stats = ot.OpsR2[op.label]
+	}
+	stats[outcome]++
+	for i, h := range hits {
+		if h > 0 {
+			stats["kmers:"+string(op.Names[i])] += uint64(h)
+			stats["reads:"+string(op.Names[i])]++
+		}
+	}
+	if op.annotate {
+		p.Annotate(r, op.label, outcome)
+	}
+	if !op.keep[screenType] {
+		p.Reject(op.label, outcome)
+		return 1
+	}
+	if op.demultiplex {
+		if screenType == ScreenHit {
+			p.WID = op.ReferencesID[best]
+		} else {
+			p.WID = op.cleanID
+		}
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package operations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+	"git.sr.ht/~vejnar/ReadKnead/lib/param"
+)
+
+func TestScreenStats(t *testing.T) {
+	tmp := t.TempDir()
+	refs := map[string]string{
+		"phix.fa": ">phix\nGAGTTTTATCGCTTCCATGACGCAGAAGTTAACACTTTCGGATATTTCTGATGAGTCGAAAAATTATCTTGATAAAGCAGGAATTACTACTGCTTGTTTACGAATTAAATCGAAGTGGACTGCTGGCGG\n",
+		"rrna.fa": ">rrna\nTACCTGGTTGATCCTGCCAGTAGCATATGCTTGTCTCAAAGATTAAGCCATGCATGTCTAAGTACGCACGGCCGGTACAGTGAAACTGCGAATGGCTCATTAAATCAGTTATGGTTCCTTTGGTCGCTCGC\n",
+	}
+	for fname, fasta := range refs {
+		if err := os.WriteFile(filepath.Join(tmp, fname), []byte(fasta), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Reference paths relative to operations file
+	ops, err := ReadOps([]byte(`[{"name": "screen", "k": 11, "references": [{"path": "phix.fa"}, {"path": "rrna.fa"}]}]`), param.Parameters{Dir: tmp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ot := NewOpStat("", "", "", "", 0, 0, 0, false, ops, nil)
+	for _, seq := range []string{
+		// phix
+		"GAGTTTTATCGCTTCCATGACGCAGAAGTTAACACTTTCG",
+		// phix (20 nt) and rrna (20 nt)
+		"GAGTTTTATCGCTTCCATGATACCTGGTTGATCCTGCCAG",
+		// Clean
+		"ACACACACACACACACACACACACACACACACACACACAC",
+	} {
+		p := fastq.ExtPair{Ok: true, R1: fastq.Record{Seq: []byte(seq)}}
+		ops[0].Transform(&p, 1, ot, 0)
+	}
+	for outcome, count := range map[string]uint64{"phix": 1, "clean": 2, "kmers:phix": 30 + 10, "reads:phix": 2, "kmers:rrna": 10, "reads:rrna": 1} {
+		if ot.OpsR1["screen"][outcome] != count {
+			t.Errorf("%s: %d, expected %d", outcome, ot.OpsR1["screen"][outcome], count)
+		}
+	}
+}
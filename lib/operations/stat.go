@@ -17,15 +17,24 @@ import (
 	"git.sr.ht/~vejnar/ReadKnead/lib/plot"
 )
 
+// Reporter is implemented by operations adding their own values to the report
+type Reporter interface {
+	Report() map[string]uint64
+}
+
 type OpStat struct {
 	OpsR1, OpsR2                                                 map[string]map[string]uint64
 	KeptPair, TotalPair                                          uint64
+	OrphanR1, OrphanR2                                           uint64
 	qualsInR1, qualsInR2, qualsOutR1, qualsOutR2                 [][]uint64
 	lengthsInR1, lengthsInR2, lengthsOutR1, lengthsOutR2         map[int]uint64
 	maxLengthInR1, maxLengthInR2, maxLengthOutR1, maxLengthOutR2 int
 	statsInPath, statsOutPath, ReportPath, Label                 string
 	paired                                                       bool
 	asciiMin                                                     int
+	opsR1, opsR2                                                 []Operation
+	DpxNames                                                     [][]byte
+	keptDpx                                                      map[int]uint64
 }
 
 func initQL(maxReadLength int, maxQual int) (quals [][]uint64, lengths map[int]uint64) {
@@ -94,7 +103,7 @@ func minMaxKeys(m map[int]uint64) (min, max int) {
 }
 
 func NewOpStat(statsInPath string, statsOutPath string, reportPath string, label string, maxReadLength int, maxQual int, asciiMin int, paired bool, opsR1 []Operation, opsR2 []Operation) *OpStat {
-	ot := OpStat{paired: paired, statsInPath: statsInPath, statsOutPath: statsOutPath, ReportPath: reportPath, Label: label, asciiMin: asciiMin}
+	ot := OpStat{paired: paired, statsInPath: statsInPath, statsOutPath: statsOutPath, ReportPath: reportPath, Label: label, asciiMin: asciiMin, opsR1: opsR1, opsR2: opsR2, keptDpx: make(map[int]uint64)}
 	// Init. for statistics: In
 	if ot.statsInPath != "" {
 		// Read1
@@ -114,11 +123,11 @@ func NewOpStat(statsInPath string, statsOutPath string, reportPath string, label
 	}
 	// Init. operations stats
 	ot.OpsR1 = make(map[string]map[string]uint64)
-	for _, op := range opsR1 {
+	for _, op := range flattenOps(opsR1) {
 		ot.OpsR1[op.Label()] = make(map[string]uint64)
 	}
 	ot.OpsR2 = make(map[string]map[string]uint64)
-	for _, op := range opsR2 {
+	for _, op := range flattenOps(opsR2) {
 		ot.OpsR2[op.Label()] = make(map[string]uint64)
 	}
 	return &ot
@@ -152,6 +161,7 @@ func (ot *OpStat) CountIn(p *fastq.ExtPair) {
 }
 
 func (ot *OpStat) CountOut(p *fastq.ExtPair) {
+	ot.keptDpx[p.WID]++
 	if ot.statsOutPath != "" {
 		// Statistics: Out, Quality
 		for i, q := range p.R1.Qual {
@@ -196,6 +206,11 @@ func (ot *OpStat) Update(otn *OpStat) {
 			updateQL(ot.qualsOutR2, ot.lengthsOutR2, otn.qualsOutR2, otn.lengthsOutR2)
 		}
 	}
+	// Max read length
+	ot.maxLengthInR1 = max(ot.maxLengthInR1, otn.maxLengthInR1)
+	ot.maxLengthInR2 = max(ot.maxLengthInR2, otn.maxLengthInR2)
+	ot.maxLengthOutR1 = max(ot.maxLengthOutR1, otn.maxLengthOutR1)
+	ot.maxLengthOutR2 = max(ot.maxLengthOutR2, otn.maxLengthOutR2)
 	// Operations stats
 	for op := range otn.OpsR1 {
 		for name, v := range otn.OpsR1[op] {
@@ -208,7 +223,12 @@ func (ot *OpStat) Update(otn *OpStat) {
 		}
 	}
 	// Counts
+	for wid, v := range otn.keptDpx {
+		ot.keptDpx[wid] += v
+	}
 	ot.KeptPair += otn.KeptPair
+	ot.OrphanR1 += otn.OrphanR1
+	ot.OrphanR2 += otn.OrphanR2
 	ot.TotalPair += otn.TotalPair
 }
 
@@ -284,6 +304,21 @@ func (ot *OpStat) Write() error {
 	}
 	// Report
 	if ot.ReportPath != "" {
+		// Add operation(s) own values
+		for _, op := range flattenOps(ot.opsR1) {
+			if rp, ok := op.(Reporter); ok {
+				for k, v := range rp.Report() {
+					ot.OpsR1[op.Label()][k] = v
+				}
+			}
+		}
+		for _, op := range flattenOps(ot.opsR2) {
+			if rp, ok := op.(Reporter); ok {
+				for k, v := range rp.Report() {
+					ot.OpsR2[op.Label()][k] = v
+				}
+			}
+		}
 		// Remove step(s) without any stats
 		for k, v := range ot.OpsR1 {
 			if len(v) == 0 {
@@ -305,6 +340,14 @@ func (ot *OpStat) Write() error {
 		statFinal["pair"]["all"] = make(map[string]uint64)
 		statFinal["pair"]["all"]["output"] = ot.KeptPair
 		statFinal["pair"]["all"]["input"] = ot.TotalPair
+		if ot.paired {
+			statFinal["pair"]["all"]["orphan_r1"] = ot.OrphanR1
+			statFinal["pair"]["all"]["orphan_r2"] = ot.OrphanR2
+		}
+		// Output per demultiplexed sample
+		for wid, name := range ot.DpxNames {
+			statFinal["pair"][string(name)] = map[string]uint64{"output": ot.keptDpx[wid]}
+		}
 		// JSON
 		report, _ := json.MarshalIndent(statFinal, "", "  ")
 		if ot.ReportPath != "-" {
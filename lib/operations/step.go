@@ -0,0 +1,233 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package operations
+
+import (
+	"fmt"
+	"strings"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+	"git.sr.ht/~vejnar/ReadKnead/lib/param"
+
+	"github.com/buger/jsonparser"
+)
+
+// Condition on outcome of a previous operation and/or read length
+type Condition struct {
+	label     string
+	read      int
+	in        []string
+	notIn     []string
+	minLength int
+	maxLength int
+}
+
+func NewCondition(data []byte) (*Condition, error) {
+	c := Condition{}
+	label, err := jsonparser.GetString(data, "label")
+	if err != nil && err != jsonparser.KeyPathNotFoundError {
+		return &c, err
+	}
+	c.label = label
+	read, err := jsonparser.GetInt(data, "read")
+	if err == jsonparser.KeyPathNotFoundError {
+		c.read = 0
+	} else if err != nil {
+		return &c, err
+	} else {
+		c.read = int(read)
+	}
+	if !(c.read == 0 || c.read == 1 || c.read == 2) {
+		return &c, fmt.Errorf("unknown read in condition: %d", c.read)
+	}
+	err = nil
+	for _, k := range []struct {
+		key    string
+		values *[]string
+	}{{"in", &c.in}, {"not_in", &c.notIn}} {
+		jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
+			if err == nil {
+				var v string
+				v, err = jsonparser.ParseString(value)
+				if err != nil {
+					return
+				}
+				*k.values = append(*k.values, v)
+			}
+		}, k.key)
+		if err != nil {
+			return &c, err
+		}
+	}
+	if c.label == "" && (len(c.in) > 0 || len(c.notIn) > 0) {
+		return &c, fmt.Errorf("condition on outcome requires label")
+	}
+	minLength, err := jsonparser.GetInt(data, "min_length")
+	if err == jsonparser.KeyPathNotFoundError {
+		c.minLength = -1
+	} else if err != nil {
+		return &c, err
+	} else {
+		c.minLength = int(minLength)
+	}
+	maxLength, err := jsonparser.GetInt(data, "max_length")
+	if err == jsonparser.KeyPathNotFoundError {
+		c.maxLength = -1
+	} else if err != nil {
+		return &c, err
+	} else {
+		c.maxLength = int(maxLength)
+	}
+	return &c, nil
+}
+
+// Eval reports whether the condition is true for read r of pair p
+func (c *Condition) Eval(p *fastq.ExtPair, r int) bool {
+	if c.read != 0 {
+		r = c.read
+	}
+	if c.label != "" {
+		outcome, _ := p.Annotation(r, c.label)
+		if len(c.in) > 0 && !contains(c.in, outcome) {
+			return false
+		}
+		if contains(c.notIn, outcome) {
+			return false
+		}
+	}
+	length := len(p.R1.Seq)
+	if r == 2 {
+		length = len(p.R2.Seq)
+	}
+	if c.minLength != -1 && length < c.minLength {
+		return false
+	}
+	if c.maxLength != -1 && length > c.maxLength {
+		return false
+	}
+	return true
+}
+
+func (c *Condition) String() string {
+	var parts []string
+	if c.label != "" {
+		parts = append(parts, "label:"+c.label)
+	}
+	if c.read != 0 {
+		parts = append(parts, fmt.Sprintf("read:%d", c.read))
+	}
+	if len(c.in) > 0 {
+		parts = append(parts, fmt.Sprintf("in:%v", c.in))
+	}
+	if len(c.notIn) > 0 {
+		parts = append(parts, fmt.Sprintf("not_in:%v", c.notIn))
+	}
+	if c.minLength != -1 {
+		parts = append(parts, fmt.Sprintf("min_length:%d", c.minLength))
+	}
+	if c.maxLength != -1 {
+		parts = append(parts, fmt.Sprintf("max_length:%d", c.maxLength))
+	}
+	return strings.Join(parts, " ")
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	FailPair = iota
+	FailMate
+)
+
+// Step runs its operation only if condition is true
+type Step struct {
+	Operation
+	cond   *Condition
+	onFail int
+}
+
+func NewStep(op Operation, data []byte, param param.Parameters) (*Step, error) {
+	s := Step{Operation: op}
+	if cond, dataType, _, err := jsonparser.Get(data, "if"); err == nil && dataType == jsonparser.Object {
+		s.cond, err = NewCondition(cond)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op.Label(), err)
+		}
+	}
+	onFail, err := jsonparser.GetString(data, "on_fail")
+	if err == jsonparser.KeyPathNotFoundError {
+		s.onFail = FailPair
+	} else if err != nil {
+		return nil, err
+	} else {
+		switch onFail {
+		case "pair":
+			s.onFail = FailPair
+		case "mate":
+			if !param.Paired {
+				return nil, fmt.Errorf("%s: on_fail mate requires paired-end reads", op.Label())
+			}
+			s.onFail = FailMate
+		default:
+			return nil, fmt.Errorf("%s: unknown on_fail: %s", op.Label(), onFail)
+		}
+	}
+	return &s, nil
+}
+
+func (s *Step) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int) int {
+	if s.cond != nil && !s.cond.Eval(p, r) {
+		if verboseLevel > 2 {
+			fmt.Printf("%s %s r%d skipped\n", s.Name(), s.Label(), r)
+		}
+		if r == 1 {
+			ot.OpsR1[s.Label()]["skipped"]++
+		} else {
+			ot.OpsR2[s.Label()]["skipped"]++
+		}
+		return 0
+	}
+	return s.Operation.Transform(p, r, ot, verboseLevel)
+}
+
+// OnFail returns if failure of operation op drops the pair (FailPair) or only the read (FailMate)
+func OnFail(op Operation) int {
+	if s, ok := op.(*Step); ok {
+		return s.onFail
+	}
+	return FailPair
+}
+
+func (s *Step) Ops() []Operation {
+	if c, ok := s.Operation.(Container); ok {
+		return c.Ops()
+	}
+	return nil
+}
+
+func (s *Step) Report() map[string]uint64 {
+	if rp, ok := s.Operation.(Reporter); ok {
+		return rp.Report()
+	}
+	return nil
+}
+
+func (s *Step) Describe() []Param {
+	params := Describe(s.Operation)
+	if s.cond != nil {
+		params = append(params, Param{"if", s.cond})
+	}
+	return append(params, Param{"on_fail", []string{"pair", "mate"}[s.onFail]})
+}
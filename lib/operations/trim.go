@@ -12,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 
+	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
 	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
 	"git.sr.ht/~vejnar/ReadKnead/lib/param"
 	"git.sr.ht/~vejnar/ReadKnead/lib/trim"
@@ -29,12 +30,23 @@ const (
 	TrimQuality
 )
 
+const (
+	TrimModeEnd = iota
+	TrimModeLinked
+	TrimModeAnywhere
+)
+
 type Trim struct {
 	name               string
 	label              string
 	end                int
 	sequences          [][]byte
 	sequencesPaired    [][]byte
+	sequencesLinked    [][]byte
+	mode               int
+	modeName           string
+	linkedRequired     string
+	times              int
 	addTrimmed         bool
 	addTrimmedRef      bool
 	addSeparator       bool
@@ -42,21 +54,31 @@ type Trim struct {
 	algoName           string
 	minSequence        int
 	minScore           float32
+	scoring            trim.Scoring
+	alignMode          trim.AlignMode
 	position           int
+	nMode              bio.NMode
 	keep               []bool
 	lengthLigand       int
 	addLigand          bool
 	addLigandSeparator bool
-	applyTrimSeq       bool
+	action             trim.Action
 	bkMatrices         []*bktrim.Matrix
+	bkMatrices5        []*bktrim.Matrix
+	bkMatrices3        []*bktrim.Matrix
+	bkParams           bkParams
 	window             int
 	unqualifiedPropMax float32
 	minQuality         int
+	autoSample         int
+	autoUnknown        bool
+	detected           map[string]uint64
 	param              param.Parameters
+	annotate           bool
 }
 
 func NewTrim(data []byte, param param.Parameters) (*Trim, error) {
-	t := Trim{name: "trim", param: param}
+	t := Trim{name: "trim", annotate: true, param: param}
 	label, err := jsonparser.GetUnsafeString(data, "label")
 	if err != nil && err != jsonparser.KeyPathNotFoundError {
 		return &t, err
@@ -66,12 +88,54 @@ func NewTrim(data []byte, param param.Parameters) (*Trim, error) {
 	} else {
 		t.label = label
 	}
+	autoSample, err := jsonparser.GetInt(data, "auto_sample")
+	if err == jsonparser.KeyPathNotFoundError {
+		t.autoSample = 100000
+	} else if err != nil {
+		return &t, err
+	} else {
+		t.autoSample = int(autoSample)
+	}
+	autoUnknown, err := jsonparser.GetBoolean(data, "auto_unknown")
+	if err == jsonparser.KeyPathNotFoundError {
+		t.autoUnknown = false
+	} else if err != nil {
+		return &t, err
+	} else {
+		t.autoUnknown = autoUnknown
+	}
 	sequence, err := jsonparser.GetUnsafeString(data, "sequence")
 	if err != nil && err != jsonparser.KeyPathNotFoundError {
 		return &t, err
 	}
-	if sequence != "" {
-		t.sequences = append(t.sequences, []byte(sequence))
+	var presetsPaired [][]byte
+	// End of catalog adapter(s), used if end isn't set (-1 if adapters differ)
+	var presetEnd int
+	addPresetEnd := func(end int) {
+		if presetEnd == 0 {
+			presetEnd = end
+		} else if end != 0 && end != presetEnd {
+			presetEnd = -1
+		}
+	}
+	if sequence == "auto" {
+		fastqs := param.FastqsR1
+		if param.Read == 2 {
+			fastqs = param.FastqsR2
+		}
+		seq, err := t.detectSequence(fastqs)
+		if err != nil {
+			return &t, err
+		}
+		t.sequences = append(t.sequences, seq)
+	} else if sequence != "" {
+		seq, seqPaired, end, err := presetSequence(sequence)
+		if err != nil {
+			return &t, err
+		}
+		t.sequences = append(t.sequences, seq)
+		presetsPaired = append(presetsPaired, seqPaired)
+		addPresetEnd(end)
 	}
 	err = nil
 	jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
@@ -81,7 +145,15 @@ func NewTrim(data []byte, param param.Parameters) (*Trim, error) {
 			if err != nil {
 				return
 			}
-			t.sequences = append(t.sequences, []byte(s))
+			var seq, seqPaired []byte
+			var end int
+			seq, seqPaired, end, err = presetSequence(s)
+			if err != nil {
+				return
+			}
+			t.sequences = append(t.sequences, seq)
+			presetsPaired = append(presetsPaired, seqPaired)
+			addPresetEnd(end)
 		}
 	}, "sequences")
 	if err != nil {
@@ -91,8 +163,18 @@ func NewTrim(data []byte, param param.Parameters) (*Trim, error) {
 	if err != nil && err != jsonparser.KeyPathNotFoundError {
 		return &t, err
 	}
-	if sequencePaired != "" {
-		t.sequencesPaired = append(t.sequencesPaired, []byte(sequencePaired))
+	if sequencePaired == "auto" {
+		seq, err := t.detectSequence(param.FastqsR2)
+		if err != nil {
+			return &t, err
+		}
+		t.sequencesPaired = append(t.sequencesPaired, seq)
+	} else if sequencePaired != "" {
+		seq, _, _, err := presetSequence(sequencePaired)
+		if err != nil {
+			return &t, err
+		}
+		t.sequencesPaired = append(t.sequencesPaired, seq)
 	}
 	err = nil
 	jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
@@ -102,12 +184,105 @@ func NewTrim(data []byte, param param.Parameters) (*Trim, error) {
 			if err != nil {
 				return
 			}
-			t.sequencesPaired = append(t.sequencesPaired, []byte(s))
+			var seq []byte
+			seq, _, _, err = presetSequence(s)
+			if err != nil {
+				return
+			}
+			t.sequencesPaired = append(t.sequencesPaired, seq)
 		}
 	}, "sequences_paired")
 	if err != nil {
 		return &t, err
 	}
+	// Paired sequence(s) from paired preset(s)
+	if len(t.sequencesPaired) == 0 && len(presetsPaired) > 0 {
+		for _, seq := range presetsPaired {
+			if len(seq) == 0 {
+				break
+			}
+			t.sequencesPaired = append(t.sequencesPaired, seq)
+		}
+		if len(t.sequencesPaired) != len(t.sequences) {
+			t.sequencesPaired = nil
+		}
+	}
+	sequenceLinked, err := jsonparser.GetUnsafeString(data, "sequence_linked")
+	if err != nil && err != jsonparser.KeyPathNotFoundError {
+		return &t, err
+	}
+	if sequenceLinked != "" {
+		seq, _, _, err := presetSequence(sequenceLinked)
+		if err != nil {
+			return &t, err
+		}
+		t.sequencesLinked = append(t.sequencesLinked, seq)
+	}
+	err = nil
+	jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err2 error) {
+		if err == nil {
+			var s string
+			s, err = jsonparser.ParseString(value)
+			if err != nil {
+				return
+			}
+			var seq []byte
+			seq, _, _, err = presetSequence(s)
+			if err != nil {
+				return
+			}
+			t.sequencesLinked = append(t.sequencesLinked, seq)
+		}
+	}, "sequences_linked")
+	if err != nil {
+		return &t, err
+	}
+	mode, err := jsonparser.GetString(data, "mode")
+	if err == jsonparser.KeyPathNotFoundError {
+		t.modeName = "end"
+	} else if err != nil {
+		return &t, err
+	} else {
+		t.modeName = mode
+	}
+	switch t.modeName {
+	case "end":
+		t.mode = TrimModeEnd
+	case "linked":
+		t.mode = TrimModeLinked
+		if len(t.sequencesLinked) == 0 {
+			return &t, fmt.Errorf("linked sequence to trim not found")
+		}
+	case "anywhere":
+		t.mode = TrimModeAnywhere
+	default:
+		return &t, fmt.Errorf("unknown trimming mode: %s", t.modeName)
+	}
+	linkedRequired, err := jsonparser.GetString(data, "linked_required")
+	if err == jsonparser.KeyPathNotFoundError {
+		t.linkedRequired = "both"
+	} else if err != nil {
+		return &t, err
+	} else {
+		t.linkedRequired = linkedRequired
+	}
+	if !(t.linkedRequired == "both" || t.linkedRequired == "5") {
+		return &t, fmt.Errorf("unknown linked requirement: %s", t.linkedRequired)
+	}
+	times, err := jsonparser.GetInt(data, "times")
+	if err == jsonparser.KeyPathNotFoundError {
+		t.times = 1
+	} else if err != nil {
+		return &t, err
+	} else {
+		t.times = int(times)
+	}
+	if t.times < 1 {
+		return &t, fmt.Errorf("times must be at least 1")
+	}
+	if t.times > 1 && t.mode == TrimModeLinked {
+		return &t, fmt.Errorf("times not available in %s mode", t.modeName)
+	}
 	addTrimmed, err := jsonparser.GetBoolean(data, "add_trimmed")
 	if err == jsonparser.KeyPathNotFoundError {
 		t.addTrimmed = false
@@ -148,9 +323,32 @@ func NewTrim(data []byte, param param.Parameters) (*Trim, error) {
 	if algoRaw != "trimqual" && len(t.sequences) == 0 {
 		return &t, fmt.Errorf("sequence to trim not found")
 	}
-	if algoRaw != "bktrim_paired" {
+	if t.mode != TrimModeEnd && (algoRaw == "bktrim_paired" || algoRaw == "trimqual") {
+		return &t, fmt.Errorf("trimming algorithm %s not available in %s mode", algoRaw, t.modeName)
+	}
+	if t.times > 1 && (algoRaw == "bktrim_paired" || algoRaw == "trimqual") {
+		return &t, fmt.Errorf("times not available with trimming algorithm %s", algoRaw)
+	}
+	if t.mode == TrimModeLinked {
+		t.end = 5
+	} else if t.mode == TrimModeAnywhere {
 		end, err := jsonparser.GetInt(data, "end")
-		if err != nil {
+		if err == jsonparser.KeyPathNotFoundError {
+			t.end = 3
+			if presetEnd > 0 {
+				t.end = presetEnd
+			}
+		} else if err != nil {
+			return &t, err
+		} else {
+			t.end = int(end)
+		}
+	} else if algoRaw != "bktrim_paired" {
+		end, err := jsonparser.GetInt(data, "end")
+		if errors.Is(err, jsonparser.KeyPathNotFoundError) && presetEnd > 0 {
+			// End of catalog adapter(s)
+			t.end = presetEnd
+		} else if err != nil {
 			if errors.Is(err, jsonparser.KeyPathNotFoundError) {
 				return &t, fmt.Errorf("%w: %v", err, "end")
 			}
@@ -162,6 +360,35 @@ func NewTrim(data []byte, param param.Parameters) (*Trim, error) {
 	if algoRaw == "align" {
 		t.algo = TrimAlignNW
 		t.algoName = algoRaw
+		t.scoring = trim.DefaultScoring
+		for _, sc := range []struct {
+			key   string
+			score *int
+		}{
+			{"match_score", &t.scoring.Match},
+			{"mismatch_score", &t.scoring.Mismatch},
+			{"gap_open", &t.scoring.GapOpen},
+			{"gap_extend", &t.scoring.GapExtend},
+		} {
+			score, err := jsonparser.GetInt(data, sc.key)
+			if err == jsonparser.KeyPathNotFoundError {
+				continue
+			} else if err != nil {
+				return &t, err
+			}
+			*sc.score = int(score)
+		}
+		alignment, err := jsonparser.GetString(data, "alignment")
+		if err == jsonparser.KeyPathNotFoundError {
+			t.alignMode = trim.AlignFreeEnd
+		} else if err != nil {
+			return &t, err
+		} else {
+			t.alignMode, err = trim.ParseAlignMode(alignment)
+			if err != nil {
+				return &t, err
+			}
+		}
 	} else if algoRaw == "bktrim" || algoRaw == "bktrim_paired" {
 		epsilon, err := jsonparser.GetFloat(data, "epsilon")
 		if err == jsonparser.KeyPathNotFoundError {
@@ -181,17 +408,37 @@ func NewTrim(data []byte, param param.Parameters) (*Trim, error) {
 		} else if err != nil {
 			return &t, err
 		}
+		t.bkParams = bkParams{epsilon, epsilonIndel, int(minOverlap)}
 		if algoRaw == "bktrim" {
 			t.algo = TrimBKTrim
 			t.algoName = algoRaw
-			t.bkMatrices = trim.NewMatrixAdapter(t.sequences, t.end, epsilon, epsilonIndel, int(minOverlap), param.AsciiMin)
+			switch t.mode {
+			case TrimModeEnd:
+				t.bkMatrices, err = trim.NewMatrixAdapter(t.sequences, t.end, epsilon, epsilonIndel, int(minOverlap), param.AsciiMin)
+			case TrimModeLinked:
+				t.bkMatrices5, err = trim.NewMatrixAdapter(t.sequences, 5, epsilon, epsilonIndel, int(minOverlap), param.AsciiMin)
+				if err == nil {
+					t.bkMatrices3, err = trim.NewMatrixAdapter(t.sequencesLinked, 3, epsilon, epsilonIndel, int(minOverlap), param.AsciiMin)
+				}
+			case TrimModeAnywhere:
+				t.bkMatrices5, err = trim.NewMatrixAdapter(t.sequences, 5, epsilon, epsilonIndel, int(minOverlap), param.AsciiMin)
+				if err == nil {
+					t.bkMatrices3, err = trim.NewMatrixAdapter(t.sequences, 3, epsilon, epsilonIndel, int(minOverlap), param.AsciiMin)
+				}
+			}
+			if err != nil {
+				return &t, err
+			}
 		} else if algoRaw == "bktrim_paired" {
 			if len(t.sequencesPaired) == 0 {
 				return &t, fmt.Errorf("sequence to trim on paired read not found")
 			}
 			t.algo = TrimBKTrimPaired
 			t.algoName = algoRaw
-			t.bkMatrices = trim.NewMatrixAdapterPaired(t.sequences, t.sequencesPaired, epsilon, epsilonIndel, int(minOverlap), param.AsciiMin)
+			t.bkMatrices, err = trim.NewMatrixAdapterPaired(t.sequences, t.sequencesPaired, epsilon, epsilonIndel, int(minOverlap), param.AsciiMin)
+			if err != nil {
+				return &t, err
+			}
 		}
 	} else if algoRaw == "search" {
 		t.algo = TrimSearch
@@ -213,8 +460,8 @@ func NewTrim(data []byte, param param.Parameters) (*Trim, error) {
 	}
 	minScore, err := jsonparser.GetFloat(data, "min_score")
 	if err == jsonparser.KeyPathNotFoundError {
-		if algoRaw == "align" {
-			t.minScore = float32(len(t.sequences[0]) * 5.)
+		if algoRaw == "align" && t.alignMode != trim.AlignSemiGlobal {
+			t.minScore = float32(len(t.sequences[0]) * t.scoring.Match)
 		} else {
 			t.minScore = 0.8
 		}
@@ -229,6 +476,17 @@ func NewTrim(data []byte, param param.Parameters) (*Trim, error) {
 	} else {
 		t.position = int(position)
 	}
+	nMode, err := jsonparser.GetString(data, "n_mode")
+	if err == jsonparser.KeyPathNotFoundError {
+		t.nMode = bio.NMismatch
+	} else if err != nil {
+		return &t, err
+	} else {
+		t.nMode, err = bio.ParseNMode(nMode)
+		if err != nil {
+			return &t, err
+		}
+	}
 	err = nil
 	t.keep = make([]bool, len(trim.TrimTypes))
 	found := false
@@ -281,11 +539,22 @@ func NewTrim(data []byte, param param.Parameters) (*Trim, error) {
 	}
 	applyTrimSeq, err := jsonparser.GetBoolean(data, "apply_trim_seq")
 	if err == jsonparser.KeyPathNotFoundError {
-		t.applyTrimSeq = true
+		t.action = trim.ActionCut
 	} else if err != nil {
 		return &t, err
+	} else if applyTrimSeq {
+		t.action = trim.ActionCut
 	} else {
-		t.applyTrimSeq = applyTrimSeq
+		t.action = trim.ActionNone
+	}
+	action, err := jsonparser.GetString(data, "action")
+	if err != nil && err != jsonparser.KeyPathNotFoundError {
+		return &t, err
+	} else if err == nil {
+		t.action, err = trim.ParseAction(action)
+		if err != nil {
+			return &t, err
+		}
 	}
 	window, err := jsonparser.GetInt(data, "window")
 	if err == jsonparser.KeyPathNotFoundError {
@@ -314,6 +583,37 @@ func NewTrim(data []byte, param param.Parameters) (*Trim, error) {
 	return &t, nil
 }
 
+// Sequence (and paired sequence) from adapter catalog or literal sequence
+func presetSequence(s string) ([]byte, []byte, int, error) {
+	if p, ok := trim.GetPreset(s); ok {
+		return []byte(p.Sequence), []byte(p.SequencePaired), p.End, nil
+	}
+	if !bio.IsIUPAC([]byte(s)) {
+		return nil, nil, 0, fmt.Errorf("unknown adapter (or not a sequence): %s", s)
+	}
+	return []byte(s), nil, 0, nil
+}
+
+// Detect adapter in the first reads of input FASTQ files
+func (t *Trim) detectSequence(fastqs []string) ([]byte, error) {
+	if len(fastqs) == 0 {
+		return nil, fmt.Errorf("no input file to detect sequence to trim")
+	}
+	seqs, err := fastq.SampleSeqs(fastqs, t.param.FqCmdIn, t.param.BufSize, t.autoSample)
+	if err != nil {
+		return nil, err
+	}
+	adapter, support, found := trim.DetectAdapter(seqs, trim.KnownAdapters, max(10, len(seqs)/1000), t.autoUnknown)
+	if !found {
+		return nil, fmt.Errorf("no sequence to trim detected in %d reads of %s", len(seqs), fastqs[0])
+	}
+	if t.detected == nil {
+		t.detected = make(map[string]uint64)
+	}
+	t.detected["detected:"+adapter.Name+":"+string(adapter.Seq)] = uint64(support)
+	return adapter.Seq, nil
+}
+
 func (op *Trim) Name() string {
 	return op.name
 }
@@ -326,118 +626,256 @@ func (op *Trim) IsThreadSafe() bool {
 	return true
 }
 
+func (op *Trim) SetAnnotate(annotate bool) {
+	op.annotate = annotate
+}
+
 func (op *Trim) GetDpx(idx int) ([][]byte, int) {
 	return [][]byte{}, idx
 }
 
+func (op *Trim) Describe() []Param {
+	params := []Param{{"sequences", byteStrings(op.sequences)}}
+	if len(op.sequencesPaired) > 0 {
+		params = append(params, Param{"sequences_paired", byteStrings(op.sequencesPaired)})
+	}
+	params = append(params, Param{"mode", op.modeName})
+	if op.mode == TrimModeLinked {
+		params = append(params, Param{"sequences_linked", byteStrings(op.sequencesLinked)}, Param{"linked_required", op.linkedRequired})
+	}
+	params = append(params, Param{"times", op.times}, Param{"algo", op.algoName})
+	if op.algo != TrimBKTrimPaired {
+		params = append(params, Param{"end", op.end})
+	}
+	switch op.algo {
+	case TrimAlignNW:
+		params = append(params, []Param{
+			{"alignment", op.alignMode},
+			{"min_score", op.minScore},
+			{"match_score", op.scoring.Match},
+			{"mismatch_score", op.scoring.Mismatch},
+			{"gap_open", op.scoring.GapOpen},
+			{"gap_extend", op.scoring.GapExtend},
+		}...)
+	case TrimBKTrim, TrimBKTrimPaired:
+		params = append(params, op.bkParams.describe()...)
+	case TrimSearch:
+		params = append(params, Param{"min_score", op.minScore})
+	case TrimMatch:
+		params = append(params, Param{"min_score", op.minScore}, Param{"position", op.position})
+	case TrimQuality:
+		params = append(params, Param{"window", op.window}, Param{"unqualified_prop_max", op.unqualifiedPropMax}, Param{"min_quality", op.minQuality})
+	}
+	return append(params, []Param{
+		{"min_sequence", op.minSequence},
+		{"n_mode", op.nMode},
+		{"keep", keepNames(op.keep, trimTypeNames())},
+		{"action", op.action},
+		{"add_trimmed", op.addTrimmed},
+		{"add_trimmed_ref", op.addTrimmedRef},
+		{"add_separator", op.addSeparator},
+		{"length_ligand", op.lengthLigand},
+	}...)
+}
+
+func (op *Trim) Report() map[string]uint64 {
+	return op.detected
+}
+
 func (op *Trim) Transform(p *fastq.ExtPair, r int, ot *OpStat, verboseLevel int) int {
-	var trimIdx int
-	var trimType trim.TrimType
-	var trimScore float32
-	var trimSeq []byte
+	var read *fastq.Record
+	var stat map[string]uint64
 	if r == 1 {
-		if verboseLevel > 2 {
-			fmt.Printf("%s %s %s %s r%d\n%s\n", op.name, op.label, op.algoName, p.R1.Name, r, p.R1.Seq)
-		}
-		switch op.algo {
-		case TrimAlignNW:
-			trimType, trimIdx, trimScore, trimSeq = trim.TrimAlign(&p.R1, op.sequences, op.minSequence, op.minScore, op.end, op.applyTrimSeq, verboseLevel)
-		case TrimBKTrim:
-			trimType, trimIdx, trimScore, trimSeq = trim.TrimBKTrim(&p.R1, op.bkMatrices, op.minSequence, op.end, op.applyTrimSeq, verboseLevel)
-		case TrimBKTrimPaired:
-			trimType, trimIdx, trimScore, trimSeq = trim.TrimBKTrimPaired(p, op.bkMatrices, op.applyTrimSeq, verboseLevel)
-		case TrimSearch:
-			trimType, trimIdx, trimScore, trimSeq = trim.TrimSearch(&p.R1, op.sequences, op.minSequence, op.minScore, op.end, op.applyTrimSeq, verboseLevel)
-		case TrimMatch:
-			trimType, trimIdx, trimScore, trimSeq = trim.TrimMatch(&p.R1, op.sequences, op.position, op.minSequence, op.minScore, op.end, op.applyTrimSeq, verboseLevel)
-		case TrimQuality:
-			trimType, trimIdx, trimScore, trimSeq = trim.TrimQuality(&p.R1, op.window, op.unqualifiedPropMax, op.minQuality, op.param.AsciiMin, op.end, op.applyTrimSeq, verboseLevel)
-		}
-		if verboseLevel > 2 {
-			fmt.Printf("%s %s length:%d score:%.2f\n", p.R1.Seq, trimType, len(p.R1.Seq), trimScore)
-		}
-		ot.OpsR1[op.label][trimType.String()]++
-		if op.keep[trimType] {
-			// Add trimmed sequence
+		read = &p.R1
+		stat = ot.OpsR1[op.label]
+	} else {
+		read = &p.R2
+		stat = ot.OpsR2[op.label]
+	}
+	if verboseLevel > 2 {
+		fmt.Printf("%s %s %s %s r%d\n%s\n", op.name, op.label, op.algoName, read.Name, r, read.Seq)
+	}
+	var trimType trim.TrimType
+	var refs, trimSeqs [][]byte
+	end := op.end
+	// Search again in trimmed read
+	for round := 1; round <= op.times; round++ {
+		var roundType trim.TrimType
+		var roundScore float32
+		var roundRefs, roundTrimSeqs [][]byte
+		switch op.mode {
+		case TrimModeEnd:
+			var trimIdx int
+			var trimSeq []byte
+			roundType, trimIdx, roundScore, trimSeq = op.find(p, read, op.end, op.sequences, op.bkMatrices, op.action, verboseLevel)
 			if len(trimSeq) > 0 {
-				if op.addTrimmedRef {
-					if op.addSeparator {
-						p.R1.Name = joinThree(p.R1.Name, []byte("#"), op.sequences[trimIdx])
-						p.R2.Name = joinThree(p.R2.Name, []byte("#"), op.sequences[trimIdx])
-					} else {
-						p.R1.Name = joinTwo(p.R1.Name, op.sequences[trimIdx])
-						p.R2.Name = joinTwo(p.R2.Name, op.sequences[trimIdx])
-					}
-				}
-				if op.addTrimmed {
-					if op.addSeparator {
-						p.R1.Name = joinThree(p.R1.Name, []byte("#"), trimSeq)
-						p.R2.Name = joinThree(p.R2.Name, []byte("#"), trimSeq)
-					} else {
-						p.R1.Name = joinTwo(p.R1.Name, trimSeq)
-						p.R2.Name = joinTwo(p.R2.Name, trimSeq)
-					}
+				var ref []byte
+				if trimIdx < len(op.sequences) {
+					ref = op.sequences[trimIdx]
 				}
+				roundRefs = append(roundRefs, ref)
+				roundTrimSeqs = append(roundTrimSeqs, trimSeq)
 			}
-			// Ligand
-			if trimType != trim.NoTrimType && op.lengthLigand > 0 {
-				pc := Clip{name: op.name, label: op.label + "-clip", end: op.end, length: op.lengthLigand, addClipped: op.addLigand, addSeparator: op.addLigandSeparator}
-				return pc.Transform(p, 1, ot, verboseLevel)
-			}
-			return 0
-		} else {
-			return 1
+		case TrimModeLinked:
+			roundType, roundScore, roundRefs, roundTrimSeqs = op.trimLinked(p, read, stat, verboseLevel)
+		case TrimModeAnywhere:
+			roundType, roundScore, end, roundRefs, roundTrimSeqs = op.trimAnywhere(p, read, stat, verboseLevel)
 		}
-	} else {
 		if verboseLevel > 2 {
-			fmt.Printf("%s %s %d %s r%d\n%s\n", op.name, op.label, op.algo, p.R2.Name, r, p.R2.Seq)
-		}
-		switch op.algo {
-		case TrimAlignNW:
-			trimType, trimIdx, trimScore, trimSeq = trim.TrimAlign(&p.R2, op.sequences, op.minSequence, op.minScore, op.end, op.applyTrimSeq, verboseLevel)
-		case TrimBKTrim:
-			trimType, trimIdx, trimScore, trimSeq = trim.TrimBKTrim(&p.R2, op.bkMatrices, op.minSequence, op.end, op.applyTrimSeq, verboseLevel)
-		case TrimSearch:
-			trimType, trimIdx, trimScore, trimSeq = trim.TrimSearch(&p.R2, op.sequences, op.minSequence, op.minScore, op.end, op.applyTrimSeq, verboseLevel)
-		case TrimMatch:
-			trimType, trimIdx, trimScore, trimSeq = trim.TrimMatch(&p.R2, op.sequences, op.position, op.minSequence, op.minScore, op.end, op.applyTrimSeq, verboseLevel)
-		case TrimQuality:
-			trimType, trimIdx, trimScore, trimSeq = trim.TrimQuality(&p.R2, op.window, op.unqualifiedPropMax, op.minQuality, op.param.AsciiMin, op.end, op.applyTrimSeq, verboseLevel)
+			fmt.Printf("%s %s length:%d score:%.2f\n", read.Seq, roundType, len(read.Seq), roundScore)
 		}
-		if verboseLevel > 2 {
-			fmt.Printf("%s %s length:%d score:%.2f\n", p.R2.Seq, trimType, len(p.R2.Seq), trimScore)
+		if op.times > 1 {
+			stat[fmt.Sprintf("round_%d:%s", round, roundType)]++
 		}
-		ot.OpsR2[op.label][trimType.String()]++
-		if op.keep[trimType] {
-			// Add trimmed sequence
-			if len(trimSeq) > 0 {
-				if op.addTrimmedRef {
-					if op.addSeparator {
-						p.R1.Name = joinThree(p.R1.Name, []byte("#"), op.sequences[trimIdx])
-						p.R2.Name = joinThree(p.R2.Name, []byte("#"), op.sequences[trimIdx])
-					} else {
-						p.R1.Name = joinTwo(p.R1.Name, op.sequences[trimIdx])
-						p.R2.Name = joinTwo(p.R2.Name, op.sequences[trimIdx])
-					}
-				}
-				if op.addTrimmed {
-					if op.addSeparator {
-						p.R1.Name = joinThree(p.R1.Name, []byte("#"), trimSeq)
-						p.R2.Name = joinThree(p.R2.Name, []byte("#"), trimSeq)
-					} else {
-						p.R1.Name = joinTwo(p.R1.Name, trimSeq)
-						p.R2.Name = joinTwo(p.R2.Name, trimSeq)
-					}
-				}
+		if round == 1 || roundType > trimType {
+			trimType = roundType
+		}
+		refs = append(refs, roundRefs...)
+		trimSeqs = append(trimSeqs, roundTrimSeqs...)
+		// Stop when nothing left to trim
+		if roundType == trim.NoTrimType || roundType == trim.TrimTooShortType || op.action != trim.ActionCut || len(read.Seq) == 0 {
+			break
+		}
+	}
+	stat[trimType.String()]++
+	if op.annotate {
+		p.Annotate(r, op.label, trimType.String())
+	}
+	if op.keep[trimType] {
+		// Add trimmed sequence
+		for i := range trimSeqs {
+			if op.addTrimmedRef && refs[i] != nil {
+				op.addName(p, refs[i])
 			}
-			// Ligand
-			if trimType != trim.NoTrimType && op.lengthLigand > 0 {
-				pc := Clip{name: op.name, label: op.label + "-clip", end: op.end, length: op.lengthLigand, addClipped: op.addLigand, addSeparator: op.addLigandSeparator}
-				return pc.Transform(p, 2, ot, verboseLevel)
+			if op.addTrimmed {
+				op.addName(p, trimSeqs[i])
 			}
-			return 0
-		} else {
-			return 1
 		}
+		// Ligand
+		if trimType != trim.NoTrimType && op.lengthLigand > 0 {
+			pc := Clip{name: op.name, label: op.label + "-clip", end: end, length: op.lengthLigand, addClipped: op.addLigand, addSeparator: op.addLigandSeparator}
+			return pc.Transform(p, r, ot, verboseLevel)
+		}
+		return 0
+	} else {
+		p.Reject(op.label, trimType.String())
+		return 1
+	}
+}
+
+// Find (and trim) one of the sequences at end of read using the operation algorithm
+func (op *Trim) find(p *fastq.ExtPair, read *fastq.Record, end int, sequences [][]byte, bkMatrices []*bktrim.Matrix, action trim.Action, verboseLevel int) (trim.TrimType, int, float32, []byte) {
+	switch op.algo {
+	case TrimAlignNW:
+		return trim.TrimAlign(read, sequences, op.minSequence, op.minScore, end, op.scoring, op.alignMode, op.nMode, action, verboseLevel)
+	case TrimBKTrim:
+		return trim.TrimBKTrim(read, bkMatrices, op.minSequence, end, op.nMode, action, verboseLevel)
+	case TrimBKTrimPaired:
+		// Pair only trimmed with read 1
+		if read == &p.R2 {
+			return trim.NoTrimType, 0, 0., nil
+		}
+		return trim.TrimBKTrimPaired(p, bkMatrices, action, verboseLevel)
+	case TrimSearch:
+		return trim.TrimSearch(read, sequences, op.minSequence, op.minScore, end, op.nMode, action, verboseLevel)
+	case TrimMatch:
+		return trim.TrimMatch(read, sequences, op.position, op.minSequence, op.minScore, end, op.nMode, action, verboseLevel)
+	case TrimQuality:
+		return trim.TrimQuality(read, op.window, op.unqualifiedPropMax, op.minQuality, op.param.AsciiMin, end, action, verboseLevel)
+	}
+	return trim.NoTrimType, 0, 0., nil
+}
+
+// Trim linked sequences: 5' sequence and 3' sequence found downstream are trimmed together
+func (op *Trim) trimLinked(p *fastq.ExtPair, read *fastq.Record, stat map[string]uint64, verboseLevel int) (trim.TrimType, float32, [][]byte, [][]byte) {
+	var refs, trimSeqs [][]byte
+	// 5' sequence
+	trimType5, trimIdx5, trimScore5, trimSeq5 := op.find(p, read, 5, op.sequences, op.bkMatrices5, trim.ActionNone, verboseLevel)
+	trimStart := 0
+	if trimType5 != trim.NoTrimType {
+		trimStart = len(trimSeq5)
+	}
+	// 3' sequence downstream of 5' sequence
+	insert := fastq.Record{Name: read.Name, Seq: read.Seq[trimStart:], Qual: read.Qual[trimStart:]}
+	trimType3, trimIdx3, trimScore3, trimSeq3 := op.find(p, &insert, 3, op.sequencesLinked, op.bkMatrices3, trim.ActionNone, verboseLevel)
+	trimEnd := len(read.Seq)
+	if trimType3 != trim.NoTrimType {
+		trimEnd = trimStart + len(insert.Seq) - len(trimSeq3)
+	}
+	if verboseLevel > 3 {
+		fmt.Printf("> 5:%s score:%.2f 3:%s score:%.2f\n", trimType5, trimScore5, trimType3, trimScore3)
+	}
+	stat["5:"+trimType5.String()]++
+	stat["3:"+trimType3.String()]++
+	// Both sequences (or only 5') required
+	if trimType5 == trim.NoTrimType || (op.linkedRequired == "both" && trimType3 == trim.NoTrimType) {
+		return trim.NoTrimType, 0., refs, trimSeqs
+	}
+	trimType := trimType5
+	trimScore := trimScore5
+	if len(trimSeq5) > 0 {
+		refs = append(refs, op.sequences[trimIdx5])
+		trimSeqs = append(trimSeqs, trimSeq5)
+	}
+	if trimType3 != trim.NoTrimType {
+		trimType = max(trimType5, trimType3)
+		trimScore += trimScore3
+		if len(trimSeq3) > 0 {
+			refs = append(refs, op.sequencesLinked[trimIdx3])
+			trimSeqs = append(trimSeqs, trimSeq3)
+		}
+	}
+	// Apply trimming
+	op.action.Apply(read, trimStart, trimEnd)
+	return trimType, trimScore, refs, trimSeqs
+}
+
+// Trim sequence found anywhere in read: removing the sequence and everything upstream (5') or downstream (3')
+func (op *Trim) trimAnywhere(p *fastq.ExtPair, read *fastq.Record, stat map[string]uint64, verboseLevel int) (trim.TrimType, float32, int, [][]byte, [][]byte) {
+	trimType5, trimIdx5, trimScore5, trimSeq5 := op.find(p, read, 5, op.sequences, op.bkMatrices5, trim.ActionNone, verboseLevel)
+	trimType3, trimIdx3, trimScore3, trimSeq3 := op.find(p, read, 3, op.sequences, op.bkMatrices3, trim.ActionNone, verboseLevel)
+	found5 := trimType5 != trim.NoTrimType
+	found3 := trimType3 != trim.NoTrimType
+	// Partial sequence overlapping read start (5') or end (3')
+	partial5 := found5 && len(trimSeq5) < len(op.sequences[trimIdx5])
+	partial3 := found3 && len(trimSeq3) < len(op.sequences[trimIdx3])
+	if verboseLevel > 3 {
+		fmt.Printf("> 5:%s score:%.2f partial:%t 3:%s score:%.2f partial:%t\n", trimType5, trimScore5, partial5, trimType3, trimScore3, partial3)
+	}
+	// Side to trim: sequence overlapping read end or found internally trimmed toward op.end
+	side := 0
+	if op.end == 5 {
+		if partial3 {
+			side = 3
+		} else if found5 {
+			side = 5
+		}
+	} else {
+		if partial5 {
+			side = 5
+		} else if found3 {
+			side = 3
+		}
+	}
+	switch side {
+	case 5:
+		stat["5:"+trimType5.String()]++
+		op.action.Apply(read, len(trimSeq5), len(read.Seq))
+		return trimType5, trimScore5, 5, [][]byte{op.sequences[trimIdx5]}, [][]byte{trimSeq5}
+	case 3:
+		stat["3:"+trimType3.String()]++
+		op.action.Apply(read, 0, len(read.Seq)-len(trimSeq3))
+		return trimType3, trimScore3, 3, [][]byte{op.sequences[trimIdx3]}, [][]byte{trimSeq3}
+	}
+	return trim.NoTrimType, 0., op.end, nil, nil
+}
+
+func (op *Trim) addName(p *fastq.ExtPair, seq []byte) {
+	if op.addSeparator {
+		p.R1.Name = joinThree(p.R1.Name, []byte("#"), seq)
+		p.R2.Name = joinThree(p.R2.Name, []byte("#"), seq)
+	} else {
+		p.R1.Name = joinTwo(p.R1.Name, seq)
+		p.R2.Name = joinTwo(p.R2.Name, seq)
 	}
 }
@@ -0,0 +1,55 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package operations
+
+import (
+	"bytes"
+	"testing"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
+	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+	"git.sr.ht/~vejnar/ReadKnead/lib/param"
+)
+
+// Pairs are trimmed by bktrim_paired with read 1 only: the operation in ops_r2 does nothing
+func TestTrimBKTrimPairedR2(t *testing.T) {
+	ops, err := ReadOps([]byte(`[{"name": "trim", "algo": "bktrim_paired", "sequence": "AGATCGGAAGAGCACACGTCTGAACTCCAGTCAC", "sequence_paired": "AGATCGGAAGAGCGTCGTGTAGGGAAAGAGTGTA"}]`), param.Parameters{Paired: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert := []byte("TTGACCAGTAGGACATGCAAGCTAGCTAGGATCCGATCG")
+	newPair := func() fastq.ExtPair {
+		r1 := append(append([]byte{}, insert...), "AGATCGGAAGAGCACACGTCTGAACT"...)
+		r2 := append(bio.ReverseComplement(insert), "AGATCGGAAGAGCGTCGTGTAGGGAA"...)
+		return fastq.ExtPair{Ok: true, R1: fastq.Record{Seq: r1, Qual: bytes.Repeat([]byte("I"), len(r1))}, R2: fastq.Record{Seq: r2, Qual: bytes.Repeat([]byte("I"), len(r2))}}
+	}
+	for _, test := range []struct {
+		r        int
+		lengthR1 int
+		lengthR2 int
+		outcome  string
+	}{
+		{1, len(insert), len(insert), "trim_align"},
+		{2, len(insert) + 26, len(insert) + 26, "no_trim"},
+	} {
+		ot := NewOpStat("", "", "", "", 0, 0, 0, true, ops, ops)
+		p := newPair()
+		ops[0].Transform(&p, test.r, ot, 0)
+		if len(p.R1.Seq) != test.lengthR1 || len(p.R2.Seq) != test.lengthR2 {
+			t.Errorf("r%d: reads of %d and %d nt, expected %d and %d nt", test.r, len(p.R1.Seq), len(p.R2.Seq), test.lengthR1, test.lengthR2)
+		}
+		stat := ot.OpsR1["trim"]
+		if test.r == 2 {
+			stat = ot.OpsR2["trim"]
+		}
+		if stat[test.outcome] != 1 {
+			t.Errorf("r%d: stats %v, expected %s", test.r, stat, test.outcome)
+		}
+	}
+}
@@ -12,4 +12,13 @@ type Parameters struct {
 	AsciiMin int
 	MaxQual  int
 	Paired   bool
+	// Input (used to sample reads)
+	FastqsR1 []string
+	FastqsR2 []string
+	FqCmdIn  []string
+	BufSize  int
+	// Read (1 or 2) of the operations being read
+	Read int
+	// Directory of relative paths in the operations being read
+	Dir string
 }
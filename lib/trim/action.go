@@ -0,0 +1,68 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package trim
+
+import (
+	"fmt"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
+)
+
+// Action applied to the trimmed part of a read
+type Action int
+
+const (
+	ActionCut Action = iota
+	ActionMask
+	ActionLowercase
+	ActionRetain
+	ActionNone
+)
+
+func (a Action) String() string {
+	return []string{"cut", "mask", "lowercase", "retain", "none"}[a]
+}
+
+var Actions = []Action{ActionCut, ActionMask, ActionLowercase, ActionRetain, ActionNone}
+
+func ParseAction(s string) (Action, error) {
+	for _, a := range Actions {
+		if s == a.String() {
+			return a, nil
+		}
+	}
+	return ActionCut, fmt.Errorf("unknown action: %s", s)
+}
+
+// Apply action to read where r.Seq[start:end] is kept (the insert) and
+// nucleotides outside are trimmed. Mask and lowercase copy the sequence.
+func (a Action) Apply(r *fastq.Record, start int, end int) {
+	switch a {
+	case ActionCut:
+		r.Seq = r.Seq[start:end]
+		r.Qual = r.Qual[start:end]
+	case ActionMask, ActionLowercase:
+		seq := make([]byte, len(r.Seq))
+		copy(seq, r.Seq)
+		for i := range seq {
+			if i >= start && i < end {
+				continue
+			}
+			if a == ActionMask {
+				seq[i] = 'N'
+			} else if seq[i] >= 'A' && seq[i] <= 'Z' {
+				seq[i] += 'a' - 'A'
+			}
+		}
+		r.Seq = seq
+	case ActionRetain:
+		r.Seq = append(r.Seq[:start:start], r.Seq[end:]...)
+		r.Qual = append(r.Qual[:start:start], r.Qual[end:]...)
+	}
+}
@@ -0,0 +1,66 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package trim
+
+import (
+	_ "embed"
+	"encoding/json"
+)
+
+type Adapter struct {
+	Name string
+	Seq  []byte
+}
+
+// Preset is a named adapter (pair) of the adapter catalog
+type Preset struct {
+	Name           string `json:"name"`
+	Sequence       string `json:"sequence"`
+	SequencePaired string `json:"sequence_paired,omitempty"`
+	End            int    `json:"end,omitempty"`
+	Description    string `json:"description"`
+}
+
+//go:embed adapters.json
+var presetsRaw []byte
+
+// Adapter catalog
+var Presets []Preset
+
+// Known 3' adapters used for adapter detection
+var KnownAdapters []Adapter
+
+func init() {
+	if err := json.Unmarshal(presetsRaw, &Presets); err != nil {
+		panic(err)
+	}
+	seen := make(map[string]bool)
+	for i, p := range Presets {
+		// 3' adapter by default; 5' oligos (TSO) are not detected
+		if p.End == 0 {
+			Presets[i].End = 3
+		} else if p.End != 3 {
+			continue
+		}
+		if !seen[p.Sequence] {
+			KnownAdapters = append(KnownAdapters, Adapter{Name: p.Name, Seq: []byte(p.Sequence)})
+			seen[p.Sequence] = true
+		}
+	}
+}
+
+// GetPreset returns the catalog adapter(s) named name
+func GetPreset(name string) (Preset, bool) {
+	for _, p := range Presets {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Preset{}, false
+}
@@ -0,0 +1,51 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package trim
+
+import (
+	"testing"
+)
+
+func TestGetPreset(t *testing.T) {
+	for _, test := range []struct {
+		name           string
+		sequence       string
+		sequencePaired string
+		end            int
+		found          bool
+	}{
+		{"truseq_r1", "AGATCGGAAGAGCACACGTCTGAACTCCAGTCAC", "", 3, true},
+		{"nextera", "CTGTCTCTTATACACATCT", "CTGTCTCTTATACACATCT", 3, true},
+		{"smarter_tso", "AAGCAGTGGTATCAACGCAGAGTACATGGG", "", 5, true},
+		{"10x_tso", "AAGCAGTGGTATCAACGCAGAGTACATGGG", "", 5, true},
+		{"nextra", "", "", 0, false},
+	} {
+		p, ok := GetPreset(test.name)
+		if ok != test.found || p.Sequence != test.sequence || p.SequencePaired != test.sequencePaired || p.End != test.end {
+			t.Errorf("%s: got %+v (found %t)", test.name, p, ok)
+		}
+	}
+}
+
+func TestKnownAdapters(t *testing.T) {
+	names := make(map[string]bool)
+	for _, a := range KnownAdapters {
+		names[a.Name] = true
+	}
+	for _, name := range []string{"truseq_r1", "truseq_r2", "nextera", "small_rna"} {
+		if !names[name] {
+			t.Errorf("%s missing from known adapters", name)
+		}
+	}
+	for _, name := range []string{"smarter_tso", "10x_tso", "truseq_single"} {
+		if names[name] {
+			t.Errorf("%s in known adapters", name)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package trim
+
+import (
+	"bytes"
+)
+
+const (
+	detectKmer          = 12
+	detectMaxLength     = 64
+	detectMinConsensus  = 0.6
+	detectMinKnownMatch = 12
+	detectTailLength    = 40
+)
+
+// DetectAdapter finds the overrepresented 3' adapter in sampled reads. The
+// most frequent k-mer in the 3' end of reads is extended into the adapter
+// sequence which is then matched against the known adapters. The assembled
+// sequence is returned if it matches no known adapter only with allowUnknown.
+// Adapter and number of supporting reads are returned.
+func DetectAdapter(seqs [][]byte, knownAdapters []Adapter, minSupport int, allowUnknown bool) (Adapter, int, bool) {
+	seed, support := mostFrequentKmer(seqs, detectKmer, detectTailLength)
+	if support >= minSupport {
+		assembled := extendKmer(seqs, seed, max(minSupport/2, 1))
+		if known, ok := matchKnown(assembled, knownAdapters); ok {
+			return known, support, true
+		}
+		if allowUnknown {
+			return Adapter{Name: "unknown", Seq: assembled}, support, true
+		}
+	}
+	// Search known adapter prefix in reads
+	var best Adapter
+	bestSupport := 0
+	for _, known := range knownAdapters {
+		prefix := known.Seq[:min(detectKmer, len(known.Seq))]
+		n := 0
+		for _, seq := range seqs {
+			if bytes.Contains(seq, prefix) {
+				n++
+			}
+		}
+		if n > bestSupport {
+			best = known
+			bestSupport = n
+		}
+	}
+	if bestSupport >= minSupport {
+		return best, bestSupport, true
+	}
+	return Adapter{}, 0, false
+}
+
+// Most frequent k-mer in the last tail nucleotides of reads
+func mostFrequentKmer(seqs [][]byte, k int, tail int) ([]byte, int) {
+	counts := make(map[uint64]int)
+	mask := uint64(1)<<(2*k) - 1
+	for _, seq := range seqs {
+		var kmer uint64
+		n := 0
+		for i := max(len(seq)-tail, 0); i < len(seq); i++ {
+			var c uint64
+			switch seq[i] {
+			case 'A':
+				c = 0
+			case 'C':
+				c = 1
+			case 'G':
+				c = 2
+			case 'T':
+				c = 3
+			default:
+				n = 0
+				continue
+			}
+			kmer = (kmer<<2 | c) & mask
+			n++
+			if n >= k {
+				counts[kmer]++
+			}
+		}
+	}
+	var best uint64
+	bestCount := 0
+	for kmer, count := range counts {
+		if count > bestCount && !isLowComplexityKmer(kmer, k) {
+			best = kmer
+			bestCount = count
+		}
+	}
+	seed := make([]byte, k)
+	for i := k - 1; i >= 0; i-- {
+		seed[i] = "ACGT"[best&3]
+		best >>= 2
+	}
+	return seed, bestCount
+}
+
+// Low-complexity k-mer: less than 3 different nucleotides
+func isLowComplexityKmer(kmer uint64, k int) bool {
+	var seen [4]bool
+	for i := 0; i < k; i++ {
+		seen[kmer&3] = true
+		kmer >>= 2
+	}
+	n := 0
+	for _, s := range seen {
+		if s {
+			n++
+		}
+	}
+	return n < 3
+}
+
+// Extend seed using the consensus of reads containing it
+func extendKmer(seqs [][]byte, seed []byte, minCoverage int) []byte {
+	var hits [][]byte
+	var starts []int
+	for _, seq := range seqs {
+		if i := bytes.Index(seq, seed); i != -1 {
+			hits = append(hits, seq)
+			starts = append(starts, i)
+		}
+	}
+	consensus := func(offset int) (byte, bool) {
+		var counts [256]int
+		coverage := 0
+		for ih, seq := range hits {
+			j := starts[ih] + offset
+			if j >= 0 && j < len(seq) {
+				counts[seq[j]]++
+				coverage++
+			}
+		}
+		if coverage < minCoverage {
+			return 0, false
+		}
+		var best byte
+		for _, nt := range []byte("ACGT") {
+			if counts[nt] > counts[best] {
+				best = nt
+			}
+		}
+		return best, float64(counts[best]) >= detectMinConsensus*float64(coverage)
+	}
+	adapter := append([]byte{}, seed...)
+	// Extend toward 3'
+	for offset := len(seed); len(adapter) < detectMaxLength; offset++ {
+		nt, ok := consensus(offset)
+		if !ok {
+			break
+		}
+		adapter = append(adapter, nt)
+	}
+	// Extend toward 5' (adapter start)
+	for offset := -1; len(adapter) < detectMaxLength; offset-- {
+		nt, ok := consensus(offset)
+		if !ok {
+			break
+		}
+		adapter = append([]byte{nt}, adapter...)
+	}
+	return adapter
+}
+
+// Find the known adapter sharing the longest sequence with the assembled adapter
+func matchKnown(assembled []byte, knownAdapters []Adapter) (Adapter, bool) {
+	var best Adapter
+	bestLength := 0
+	for _, known := range knownAdapters {
+		// Known adapter starting inside assembled adapter or the reverse
+		for i := 0; i < len(assembled); i++ {
+			if l := commonPrefix(assembled[i:], known.Seq); l > bestLength {
+				best = known
+				bestLength = l
+			}
+		}
+		for i := 1; i < len(known.Seq); i++ {
+			if l := commonPrefix(assembled, known.Seq[i:]); l > bestLength {
+				best = known
+				bestLength = l
+			}
+		}
+	}
+	return best, bestLength >= detectMinKnownMatch
+}
+
+func commonPrefix(a []byte, b []byte) int {
+	l := 0
+	for l < len(a) && l < len(b) && a[l] == b[l] {
+		l++
+	}
+	return l
+}
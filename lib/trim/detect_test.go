@@ -0,0 +1,92 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package trim
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDetectAdapter(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomSeq := func(n int) []byte {
+		seq := make([]byte, n)
+		for i := range seq {
+			seq[i] = "ACGT"[rng.Intn(4)]
+		}
+		return seq
+	}
+	// Reads with a genomic repeat in 5' and an adapter in 3'
+	sample := func(adapter string) [][]byte {
+		var seqs [][]byte
+		for i := 0; i < 50; i++ {
+			seq := append([]byte("GGCCGGGCGCGGTGGCTCAC"), randomSeq(10+i%30)...)
+			seqs = append(seqs, append(seq, adapter...)[:76])
+		}
+		return seqs
+	}
+	for _, test := range []struct {
+		adapter      string
+		allowUnknown bool
+		name         string
+		found        bool
+	}{
+		{"AGATCGGAAGAGCACACGTCTGAACTCCAGTCACGTACTAGCATCTCGTATGCCGTCTTCTG", false, "truseq_r1", true},
+		{"CTGTCTCTTATACACATCTCCGAGCCCACGAGACTAAGGCGAATCTCGTATGCCGTCTTCTG", false, "nextera", true},
+		{"GTTCAGAGTTCTACAGTCCGACGATCGTTCAGAGTTCTACAGTCCGACGATCTTCAGAGTTC", false, "", false},
+		{"GTTCAGAGTTCTACAGTCCGACGATCGTTCAGAGTTCTACAGTCCGACGATCTTCAGAGTTC", true, "unknown", true},
+	} {
+		adapter, _, found := DetectAdapter(sample(test.adapter), KnownAdapters, 10, test.allowUnknown)
+		if found != test.found || adapter.Name != test.name {
+			t.Errorf("%s: detected %s (found %t), expected %s", test.adapter, adapter.Name, found, test.name)
+		}
+	}
+}
+
+func TestMostFrequentKmer(t *testing.T) {
+	seqs := [][]byte{
+		[]byte("AAAAAAAAAAAAAAAAAAAAGATCGGAAG"),
+		[]byte("CCCCCCCCCCCCCCCCCCCCGATCGGAAG"),
+		[]byte("GATCGGAAGTTTTTTTTTTTTTTTTTTTT"),
+	}
+	for _, test := range []struct {
+		tail  int
+		kmer  string
+		count int
+	}{
+		// Poly-A/C/T k-mers are more frequent but low-complexity
+		{29, "GATCGGAAG", 3},
+		// k-mer outside of read tails not counted
+		{10, "GATCGGAAG", 2},
+	} {
+		kmer, count := mostFrequentKmer(seqs, 9, test.tail)
+		if string(kmer) != test.kmer || count != test.count {
+			t.Errorf("tail %d: got %s (%d), expected %s (%d)", test.tail, kmer, count, test.kmer, test.count)
+		}
+	}
+}
+
+func TestMatchKnown(t *testing.T) {
+	for _, test := range []struct {
+		assembled string
+		name      string
+		found     bool
+	}{
+		// Assembled adapter starting before or inside known adapter
+		{"TTGACAGATCGGAAGAGCACACGTCTG", "truseq_r1", true},
+		{"GAAGAGCACACGTCTGAACTCC", "truseq_r1", true},
+		{"CTGTCTCTTATACACATCTCCGAG", "nextera", true},
+		{"GTTCAGAGTTCTACAGTCCGACGATC", "", false},
+	} {
+		adapter, found := matchKnown([]byte(test.assembled), KnownAdapters)
+		if found != test.found || (found && adapter.Name != test.name) {
+			t.Errorf("%s: matched %s (found %t), expected %s", test.assembled, adapter.Name, found, test.name)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package trim
+
+import (
+	"fmt"
+	"math"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
+)
+
+type AlignMode int
+
+const (
+	// Gaps at both ends are not penalized; raw score
+	AlignFreeEnd AlignMode = iota
+	// Gaps at both ends are penalized
+	AlignGlobal
+	// Gaps at both ends are not penalized; score divided by best possible score of the overlap
+	AlignSemiGlobal
+)
+
+func (m AlignMode) String() string {
+	return []string{"free_end", "global", "semiglobal"}[m]
+}
+
+func ParseAlignMode(s string) (AlignMode, error) {
+	for _, m := range []AlignMode{AlignFreeEnd, AlignGlobal, AlignSemiGlobal} {
+		if s == m.String() {
+			return m, nil
+		}
+	}
+	return AlignFreeEnd, fmt.Errorf("unknown alignment: %s", s)
+}
+
+// Alignment scores. A gap of length L costs GapOpen + (L-1) * GapExtend.
+type Scoring struct {
+	Match     int
+	Mismatch  int
+	GapOpen   int
+	GapExtend int
+}
+
+var DefaultScoring = Scoring{Match: 5, Mismatch: -10, GapOpen: -10, GapExtend: -10}
+
+const (
+	nwNone byte = iota
+	nwDiag
+	nwUp
+	nwLeft
+)
+
+// Needleman–Wunsch alignment of read and adaptor with affine gaps (Gotoh)
+// and IUPAC-aware scoring.
+func alignNW(read []byte, adaptor []byte, sc Scoring, mode AlignMode, nMode bio.NMode) ([]byte, []byte, int) {
+	const minScore = math.MinInt32
+	freeEndGaps := mode != AlignGlobal
+	rows, cols := len(read)+1, len(adaptor)+1
+	// h: best score; x: best score ending with gap in adaptor; y: best score ending with gap in read
+	h := make([]int, rows*cols)
+	x := make([]int, rows*cols)
+	y := make([]int, rows*cols)
+	pointer := make([]byte, rows*cols)
+	// Gap extended (or opened from h)
+	xExt := make([]bool, rows*cols)
+	yExt := make([]bool, rows*cols)
+	x[0], y[0] = minScore, minScore
+	for i := 1; i < rows; i++ {
+		if !freeEndGaps {
+			h[i*cols] = sc.GapOpen + (i-1)*sc.GapExtend
+		}
+		x[i*cols], y[i*cols] = h[i*cols], minScore
+		pointer[i*cols] = nwUp
+		xExt[i*cols] = i > 1
+	}
+	for j := 1; j < cols; j++ {
+		if !freeEndGaps {
+			h[j] = sc.GapOpen + (j-1)*sc.GapExtend
+		}
+		x[j], y[j] = minScore, h[j]
+		pointer[j] = nwLeft
+		yExt[j] = j > 1
+	}
+	// Fill matrices
+	for i := 1; i < rows; i++ {
+		for j := 1; j < cols; j++ {
+			k := i*cols + j
+			s := bio.CompareIUPAC(read[i-1], adaptor[j-1], nMode)
+			diag := h[k-cols-1] + sc.Mismatch + int(float32(sc.Match-sc.Mismatch)*s)
+			// Opening preferred over extension with equal score
+			x[k] = h[k-cols] + sc.GapOpen
+			if x[k-cols] != minScore && x[k-cols]+sc.GapExtend > x[k] {
+				x[k] = x[k-cols] + sc.GapExtend
+				xExt[k] = true
+			}
+			y[k] = h[k-1] + sc.GapOpen
+			if y[k-1] != minScore && y[k-1]+sc.GapExtend > y[k] {
+				y[k] = y[k-1] + sc.GapExtend
+				yExt[k] = true
+			}
+			best := max(diag, x[k], y[k])
+			// Gaps preferred over match/mismatch with equal score
+			if best == x[k] {
+				pointer[k] = nwUp
+			} else if best == y[k] {
+				pointer[k] = nwLeft
+			} else {
+				pointer[k] = nwDiag
+			}
+			h[k] = best
+		}
+	}
+	// Score and end of alignment
+	i, j := rows-1, cols-1
+	score := h[rows*cols-1]
+	if freeEndGaps {
+		// Best score in last row or last column, then gaps to the corner
+		maxRow, maxCol := 0, 0
+		if mode == AlignSemiGlobal {
+			maxRow, maxCol = minScore, minScore
+		}
+		var iCol, iRow int
+		for jj := 0; jj < cols; jj++ {
+			if h[(rows-1)*cols+jj] > maxRow {
+				maxRow = h[(rows-1)*cols+jj]
+				iCol = jj
+			}
+		}
+		for ii := 0; ii < rows; ii++ {
+			if h[ii*cols+cols-1] > maxCol {
+				maxCol = h[ii*cols+cols-1]
+				iRow = ii
+			}
+		}
+		if maxRow > maxCol {
+			score = maxRow
+			j = iCol
+		} else {
+			score = maxCol
+			i = iRow
+		}
+	}
+	alignRead := make([]byte, 0, rows+cols)
+	alignAdaptor := make([]byte, 0, rows+cols)
+	for jj := cols - 1; jj > j; jj-- {
+		alignRead = append(alignRead, '-')
+		alignAdaptor = append(alignAdaptor, adaptor[jj-1])
+	}
+	for ii := rows - 1; ii > i; ii-- {
+		alignRead = append(alignRead, read[ii-1])
+		alignAdaptor = append(alignAdaptor, '-')
+	}
+	// Traceback
+	p := pointer[i*cols+j]
+	for p != nwNone {
+		k := i*cols + j
+		switch p {
+		case nwDiag:
+			alignRead = append(alignRead, read[i-1])
+			alignAdaptor = append(alignAdaptor, adaptor[j-1])
+			i--
+			j--
+			p = pointer[k-cols-1]
+		case nwUp:
+			alignRead = append(alignRead, read[i-1])
+			alignAdaptor = append(alignAdaptor, '-')
+			i--
+			if xExt[k] {
+				p = nwUp
+			} else {
+				p = pointer[k-cols]
+			}
+		case nwLeft:
+			alignRead = append(alignRead, '-')
+			alignAdaptor = append(alignAdaptor, adaptor[j-1])
+			j--
+			if yExt[k] {
+				p = nwLeft
+			} else {
+				p = pointer[k-1]
+			}
+		}
+	}
+	return bio.Reverse(alignRead), bio.Reverse(alignAdaptor), score
+}
+
+// Number of adaptor nucleotides aligned within the read (end gaps excluded)
+func alignOverlap(alignRead []byte, alignAdaptor []byte) int {
+	start, end := 0, len(alignRead)
+	for start < end && (alignRead[start] == '-' || alignAdaptor[start] == '-') {
+		start++
+	}
+	for end > start && (alignRead[end-1] == '-' || alignAdaptor[end-1] == '-') {
+		end--
+	}
+	n := 0
+	for i := start; i < end; i++ {
+		if alignAdaptor[i] != '-' {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,73 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package trim
+
+import (
+	"math/rand"
+	"testing"
+
+	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
+
+	"github.com/vejnar/nwalgo"
+)
+
+func randomSeq(rng *rand.Rand, n int) []byte {
+	seq := make([]byte, n)
+	for i := range seq {
+		seq[i] = "ACGT"[rng.Intn(4)]
+	}
+	return seq
+}
+
+// With equal gap open and extend costs, alignment matches nwalgo (linear gaps)
+func TestAlignNWLinear(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, sc := range []Scoring{DefaultScoring, {Match: 1, Mismatch: -1, GapOpen: -1, GapExtend: -1}, {Match: 2, Mismatch: -3, GapOpen: -5, GapExtend: -5}} {
+		for _, mode := range []AlignMode{AlignFreeEnd, AlignGlobal} {
+			for range 200 {
+				read, adaptor := randomSeq(rng, 5+rng.Intn(40)), randomSeq(rng, 5+rng.Intn(20))
+				alignRead, alignAdaptor, score := alignNW(read, adaptor, sc, mode, bio.NMismatch)
+				nwRead, nwAdaptor, nwScore := nwalgo.AlignBytes(read, adaptor, sc.Match, sc.Mismatch, sc.GapOpen, mode == AlignFreeEnd)
+				if string(alignRead) != string(nwRead) || string(alignAdaptor) != string(nwAdaptor) || score != nwScore {
+					t.Errorf("%s %s %+v %s:\n%s\n%s %d\nexpected\n%s\n%s %d", read, adaptor, sc, mode, alignRead, alignAdaptor, score, nwRead, nwAdaptor, nwScore)
+				}
+			}
+		}
+	}
+}
+
+func TestAlignNW(t *testing.T) {
+	affine := Scoring{Match: 5, Mismatch: -10, GapOpen: -10, GapExtend: -1}
+	for _, test := range []struct {
+		read         string
+		adaptor      string
+		sc           Scoring
+		mode         AlignMode
+		alignRead    string
+		alignAdaptor string
+		score        int
+		overlap      int
+	}{
+		// One gap of 3 (affine) instead of mismatches
+		{"AAAAGGGCCCC", "AAAACCCC", affine, AlignGlobal, "AAAAGGGCCCC", "AAAA---CCCC", 28, 8},
+		// End gaps free: adaptor at 3' end of read
+		{"CTGACTGAAGATCGG", "AGATCGGAAGAGC", DefaultScoring, AlignFreeEnd, "CTGACTGAAGATCGG------", "--------AGATCGGAAGAGC", 35, 7},
+		{"CTGACTGAAGATCGG", "AGATCGGAAGAGC", DefaultScoring, AlignSemiGlobal, "CTGACTGAAGATCGG------", "--------AGATCGGAAGAGC", 35, 7},
+		// IUPAC letters in adaptor
+		{"CTGACTGAAGATCGG", "AGNTCGGAAGAGC", DefaultScoring, AlignFreeEnd, "CTGACTGAAGATCGG------", "--------AGNTCGGAAGAGC", 35, 7},
+	} {
+		alignRead, alignAdaptor, score := alignNW([]byte(test.read), []byte(test.adaptor), test.sc, test.mode, bio.NMatch)
+		if string(alignRead) != test.alignRead || string(alignAdaptor) != test.alignAdaptor || score != test.score {
+			t.Errorf("%s %s %s:\n%s\n%s %d\nexpected\n%s\n%s %d", test.read, test.adaptor, test.mode, alignRead, alignAdaptor, score, test.alignRead, test.alignAdaptor, test.score)
+		}
+		if overlap := alignOverlap(alignRead, alignAdaptor); overlap != test.overlap {
+			t.Errorf("%s %s %s: overlap %d, expected %d", test.read, test.adaptor, test.mode, overlap, test.overlap)
+		}
+	}
+}
@@ -13,13 +13,12 @@ import (
 	"fmt"
 	"strings"
 
+	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
 	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
-
-	"github.com/vejnar/nwalgo"
 )
 
 // Trim by aligning
-func TrimAlign(r *fastq.Record, adaptors [][]byte, minAdaptor int, minScore float32, trimSide int, applyTrimSeq bool, verboseLevel int) (TrimType, int, float32, []byte) {
+func TrimAlign(r *fastq.Record, adaptors [][]byte, minAdaptor int, minScore float32, trimSide int, scoring Scoring, alignMode AlignMode, nMode bio.NMode, action Action, verboseLevel int) (TrimType, int, float32, []byte) {
 	var trimIdx, trimStart, trimEnd, alignScore int
 	var trimType TrimType
 	var trimScore, tmpScore float32
@@ -28,16 +27,23 @@ func TrimAlign(r *fastq.Record, adaptors [][]byte, minAdaptor int, minScore floa
 	// Find matching adaptor
 	for iadaptor, adaptor := range adaptors {
 		// Find the adaptor by search first
-		adaptorIndex := bytes.Index(r.Seq, adaptor)
+		adaptorIndex := bio.IndexIUPAC(r.Seq, adaptor, nMode)
 		if adaptorIndex == -1 {
 			var alignSeq, alignAdaptor []byte
 			var adaptorIndexAln int
 			var minAdaptorOk bool
 			// Align
-			alignSeq, alignAdaptor, alignScore = nwalgo.AlignBytes(r.Seq, adaptor, 5, -10, -10, true)
+			alignSeq, alignAdaptor, alignScore = alignNW(r.Seq, adaptor, scoring, alignMode, nMode)
 			tmpScore = float32(alignScore)
+			if alignMode == AlignSemiGlobal {
+				if overlap := alignOverlap(alignSeq, alignAdaptor); overlap > 0 {
+					tmpScore /= float32(scoring.Match * overlap)
+				} else {
+					tmpScore = 0.
+				}
+			}
 			if verboseLevel > 3 {
-				fmt.Printf("> %s\n> %s %.0f\n", alignSeq, alignAdaptor, tmpScore)
+				fmt.Printf("> %s\n> %s %.2f\n", alignSeq, alignAdaptor, tmpScore)
 			}
 			// Check alignment quality
 			if minAdaptor == 0 {
@@ -59,7 +65,7 @@ func TrimAlign(r *fastq.Record, adaptors [][]byte, minAdaptor int, minScore floa
 				if adaptorIndexAln == -1 {
 					minAdaptorOk = false
 				} else {
-					minAdaptorOk = bytes.Equal(alignSeq[adaptorIndexAln:adaptorIndexAln+minAdaptor], requiredAdaptorPart)
+					minAdaptorOk = bio.EqualIUPAC(alignSeq[adaptorIndexAln:adaptorIndexAln+minAdaptor], requiredAdaptorPart, nMode)
 				}
 			}
 			if adaptorIndexAln != -1 && minAdaptorOk && minScore <= tmpScore && tmpScore > trimScore {
@@ -77,8 +83,12 @@ func TrimAlign(r *fastq.Record, adaptors [][]byte, minAdaptor int, minScore floa
 		} else {
 			trimType = TrimExactType
 			trimIdx = iadaptor
-			// Set score to 10x per match
-			trimScore = 10. * float32(len(adaptor))
+			if alignMode == AlignSemiGlobal {
+				trimScore = 1.
+			} else {
+				// Set score to 10x per match
+				trimScore = 10. * float32(len(adaptor))
+			}
 			if trimSide == 5 {
 				trimStart = adaptorIndex + len(adaptor)
 				trimSeq = r.Seq[:trimStart]
@@ -87,14 +97,13 @@ func TrimAlign(r *fastq.Record, adaptors [][]byte, minAdaptor int, minScore floa
 				trimSeq = r.Seq[trimEnd:]
 			}
 			if verboseLevel > 3 {
-				fmt.Printf("> %s\n> %s%s %.0f\n", r.Seq, strings.Repeat("-", adaptorIndex), adaptor, trimScore)
+				fmt.Printf("> %s\n> %s%s %.2f\n", r.Seq, strings.Repeat("-", adaptorIndex), adaptor, trimScore)
 			}
 		}
 	}
 	// Apply trimming
-	if applyTrimSeq && trimType != NoTrimType {
-		r.Seq = r.Seq[trimStart:trimEnd]
-		r.Qual = r.Qual[trimStart:trimEnd]
+	if trimType != NoTrimType {
+		action.Apply(r, trimStart, trimEnd)
 	}
 	return trimType, trimIdx, trimScore, trimSeq
 }
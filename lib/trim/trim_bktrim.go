@@ -9,16 +9,29 @@
 package trim
 
 import (
-	"bytes"
 	"fmt"
 	"strings"
 
+	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
 	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
 
 	"git.sr.ht/~vejnar/bktrim"
 )
 
-func NewMatrixAdapter(adaptors [][]byte, trimSide int, epsilon float64, epsilonIndel float64, minOverlap int, baseQual int) []*bktrim.Matrix {
+// Degenerate IUPAC letters are mismatches in bktrim alignment: adaptors must be A, C, G or T only
+func checkAdaptors(adaptors [][]byte) error {
+	for _, adaptor := range adaptors {
+		if bio.IsDegenerate(adaptor) {
+			return fmt.Errorf("degenerate (IUPAC) sequence not supported by bktrim: %s", adaptor)
+		}
+	}
+	return nil
+}
+
+func NewMatrixAdapter(adaptors [][]byte, trimSide int, epsilon float64, epsilonIndel float64, minOverlap int, baseQual int) ([]*bktrim.Matrix, error) {
+	if err := checkAdaptors(adaptors); err != nil {
+		return nil, err
+	}
 	var ms []*bktrim.Matrix
 	for _, adaptor := range adaptors {
 		m := bktrim.NewMatrix(epsilon, epsilonIndel, minOverlap, baseQual)
@@ -29,10 +42,13 @@ func NewMatrixAdapter(adaptors [][]byte, trimSide int, epsilon float64, epsilonI
 		}
 		ms = append(ms, m)
 	}
-	return ms
+	return ms, nil
 }
 
-func NewMatrixAdapterPaired(adaptors [][]byte, adaptorsPaired [][]byte, epsilon float64, epsilonIndel float64, minOverlap int, baseQual int) []*bktrim.Matrix {
+func NewMatrixAdapterPaired(adaptors [][]byte, adaptorsPaired [][]byte, epsilon float64, epsilonIndel float64, minOverlap int, baseQual int) ([]*bktrim.Matrix, error) {
+	if err := checkAdaptors(append(append([][]byte{}, adaptors...), adaptorsPaired...)); err != nil {
+		return nil, err
+	}
 	var ms []*bktrim.Matrix
 	for iadaptor, adaptor := range adaptors {
 		m := bktrim.NewMatrix(epsilon, epsilonIndel, minOverlap, baseQual)
@@ -40,11 +56,11 @@ func NewMatrixAdapterPaired(adaptors [][]byte, adaptorsPaired [][]byte, epsilon
 		m.AddAdapter(adaptorsPaired[iadaptor], bktrim.TRIM_TAIL, 1)
 		ms = append(ms, m)
 	}
-	return ms
+	return ms, nil
 }
 
 // Trim by aligning
-func TrimBKTrim(r *fastq.Record, bkMatrices []*bktrim.Matrix, minAdaptor int, trimSide int, applyTrimSeq bool, verboseLevel int) (TrimType, int, float32, []byte) {
+func TrimBKTrim(r *fastq.Record, bkMatrices []*bktrim.Matrix, minAdaptor int, trimSide int, nMode bio.NMode, action Action, verboseLevel int) (TrimType, int, float32, []byte) {
 	var trimIdx, trimStart, trimEnd int
 	var trimType TrimType
 	var trimScore, tmpScore float32
@@ -54,7 +70,7 @@ func TrimBKTrim(r *fastq.Record, bkMatrices []*bktrim.Matrix, minAdaptor int, tr
 	// Find matching adaptor
 	for im, m := range bkMatrices {
 		// Find the adaptor by search first
-		adaptorIndex := bytes.Index(r.Seq, m.Adapter1.Seq)
+		adaptorIndex := bio.IndexIUPAC(r.Seq, m.Adapter1.Seq, nMode)
 		if adaptorIndex == -1 {
 			determined, sol := m.FindAdapter(r.Seq, r.Qual)
 			if determined {
@@ -81,13 +97,13 @@ func TrimBKTrim(r *fastq.Record, bkMatrices []*bktrim.Matrix, minAdaptor int, tr
 							if sol.Pos <= minAdaptor {
 								alignQual = false
 							} else {
-								alignQual = bytes.Equal(m.Adapter1.Seq[len(m.Adapter1.Seq)-minAdaptor:], r.Seq[sol.Pos-minAdaptor:sol.Pos])
+								alignQual = bio.EqualIUPAC(r.Seq[sol.Pos-minAdaptor:sol.Pos], m.Adapter1.Seq[len(m.Adapter1.Seq)-minAdaptor:], nMode)
 							}
 						} else if trimSide == 3 {
 							if len(r.Seq) < sol.Pos+minAdaptor {
 								alignQual = false
 							} else {
-								alignQual = bytes.Equal(m.Adapter1.Seq[:minAdaptor], r.Seq[sol.Pos:sol.Pos+minAdaptor])
+								alignQual = bio.EqualIUPAC(r.Seq[sol.Pos:sol.Pos+minAdaptor], m.Adapter1.Seq[:minAdaptor], nMode)
 							}
 						}
 					}
@@ -119,15 +135,14 @@ func TrimBKTrim(r *fastq.Record, bkMatrices []*bktrim.Matrix, minAdaptor int, tr
 		}
 	}
 	// Apply trimming
-	if applyTrimSeq && trimType != NoTrimType {
-		r.Seq = r.Seq[trimStart:trimEnd]
-		r.Qual = r.Qual[trimStart:trimEnd]
+	if trimType != NoTrimType {
+		action.Apply(r, trimStart, trimEnd)
 	}
 	return trimType, trimIdx, trimScore, trimSeq
 }
 
 // Trim by aligning
-func TrimBKTrimPaired(p *fastq.ExtPair, bkMatrices []*bktrim.Matrix, applyTrimSeq bool, verboseLevel int) (TrimType, int, float32, []byte) {
+func TrimBKTrimPaired(p *fastq.ExtPair, bkMatrices []*bktrim.Matrix, action Action, verboseLevel int) (TrimType, int, float32, []byte) {
 	var trimIdx int
 	var trimType TrimType
 	var trimScore float64
@@ -147,13 +162,12 @@ func TrimBKTrimPaired(p *fastq.ExtPair, bkMatrices []*bktrim.Matrix, applyTrimSe
 		}
 	}
 	// Apply trimming
-	if applyTrimSeq && trimType != NoTrimType {
+	if action != ActionNone && trimType != NoTrimType {
 		if trimMatrix.CombinePairSeqs(p.R1.Seq, p.R1.Qual, p.R2.Seq, p.R2.Qual, sol1.Pos, sol2.Pos) {
-			p.R1.Seq = p.R1.Seq[:sol1.Pos]
-			p.R1.Qual = p.R1.Qual[:sol1.Pos]
-			p.R2.Seq = p.R2.Seq[:sol2.Pos]
-			p.R2.Qual = p.R2.Qual[:sol2.Pos]
-			return trimType, trimIdx, float32(trimScore), p.R1.Seq[sol1.Pos:]
+			// Trimmed sequence not reported (adapter position found by pair overlap)
+			action.Apply(&p.R1, 0, sol1.Pos)
+			action.Apply(&p.R2, 0, sol2.Pos)
+			return trimType, trimIdx, float32(trimScore), trimSeq
 		}
 	}
 	return trimType, trimIdx, float32(trimScore), trimSeq
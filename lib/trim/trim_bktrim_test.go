@@ -0,0 +1,27 @@
+//
+// Copyright © 2025 Charles E. Vejnar
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://www.mozilla.org/MPL/2.0/.
+//
+
+package trim
+
+import (
+	"testing"
+)
+
+func TestNewMatrixAdapterIUPAC(t *testing.T) {
+	if _, err := NewMatrixAdapter([][]byte{[]byte("AGATCGGAAGAGC")}, 3, 0.1, 0.03, 3, 33); err != nil {
+		t.Errorf("got error %v", err)
+	}
+	_, err := NewMatrixAdapter([][]byte{[]byte("AGATCGGAAGAGC"), []byte("NNNNAGATCGG")}, 3, 0.1, 0.03, 3, 33)
+	if err == nil || err.Error() != "degenerate (IUPAC) sequence not supported by bktrim: NNNNAGATCGG" {
+		t.Errorf("got error %v", err)
+	}
+	_, err = NewMatrixAdapterPaired([][]byte{[]byte("AGATCGGAAGAGC")}, [][]byte{[]byte("AGATCGGRAGAGC")}, 0.1, 0.03, 3, 33)
+	if err == nil || err.Error() != "degenerate (IUPAC) sequence not supported by bktrim: AGATCGGRAGAGC" {
+		t.Errorf("got error %v", err)
+	}
+}
@@ -9,14 +9,15 @@
 package trim
 
 import (
+	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
 	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
 )
 
 // Trim by matching
-func TrimMatch(r *fastq.Record, adaptors [][]byte, position int, minAdaptor int, minScore float32, trimSide int, applyTrimSeq bool, verboseLevel int) (TrimType, int, float32, []byte) {
-	var trimIdx, trimStart, trimEnd, adLength, nMatch int
+func TrimMatch(r *fastq.Record, adaptors [][]byte, position int, minAdaptor int, minScore float32, trimSide int, nMode bio.NMode, action Action, verboseLevel int) (TrimType, int, float32, []byte) {
+	var trimIdx, trimStart, trimEnd, adLength int
 	var trimType TrimType
-	var trimScore, tmpScore float32
+	var trimScore, tmpScore, nMatch float32
 	var trimSeq []byte
 	trimEnd = len(r.Seq)
 	// Find matching adaptor
@@ -25,13 +26,11 @@ func TrimMatch(r *fastq.Record, adaptors [][]byte, position int, minAdaptor int,
 		// Count mismatch(es) with adaptor
 		nMatch = 0
 		for i := 0; i < min(adLength, len(r.Seq)-position); i++ {
-			if ad[i] == r.Seq[position+i] {
-				nMatch++
-			}
+			nMatch += bio.CompareIUPAC(r.Seq[position+i], ad[i], nMode)
 		}
 		// Trim or not trim
-		tmpScore = float32(nMatch) / float32(adLength)
-		if nMatch == adLength {
+		tmpScore = nMatch / float32(adLength)
+		if tmpScore == 1. {
 			trimIdx = iad
 			trimType = TrimExactType
 			trimScore = 1.
@@ -44,17 +43,14 @@ func TrimMatch(r *fastq.Record, adaptors [][]byte, position int, minAdaptor int,
 	}
 	if trimType != NoTrimType {
 		if trimSide == 5 {
-			trimStart = position + len(adaptors[trimIdx])
+			trimStart = min(position+len(adaptors[trimIdx]), len(r.Seq))
 			trimSeq = r.Seq[:trimStart]
 		} else if trimSide == 3 {
 			trimEnd = position
 			trimSeq = r.Seq[trimEnd:]
 		}
 		// Apply trimming
-		if applyTrimSeq {
-			r.Seq = r.Seq[trimStart:trimEnd]
-			r.Qual = r.Qual[trimStart:trimEnd]
-		}
+		action.Apply(r, trimStart, trimEnd)
 	}
 	return trimType, trimIdx, trimScore, trimSeq
 }
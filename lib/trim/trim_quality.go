@@ -15,7 +15,7 @@ import (
 )
 
 // Trim by quality
-func TrimQuality(r *fastq.Record, window int, unqualifiedPropMax float32, minQuality int, asciiMin int, trimSide int, applyTrimSeq bool, verboseLevel int) (TrimType, int, float32, []byte) {
+func TrimQuality(r *fastq.Record, window int, unqualifiedPropMax float32, minQuality int, asciiMin int, trimSide int, action Action, verboseLevel int) (TrimType, int, float32, []byte) {
 	var nLow, trimIdx, trimStart, trimEnd int
 	var trimType TrimType
 	var trimScore, tmpScore float32
@@ -90,9 +90,8 @@ func TrimQuality(r *fastq.Record, window int, unqualifiedPropMax float32, minQua
 			}
 		}
 		// Apply trimming
-		if applyTrimSeq && trimType != NoTrimType {
-			r.Seq = r.Seq[trimStart:trimEnd]
-			r.Qual = r.Qual[trimStart:trimEnd]
+		if trimType != NoTrimType {
+			action.Apply(r, trimStart, trimEnd)
 		}
 	}
 	return trimType, trimIdx, trimScore, trimSeq
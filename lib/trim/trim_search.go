@@ -9,14 +9,15 @@
 package trim
 
 import (
+	"git.sr.ht/~vejnar/ReadKnead/lib/bio"
 	"git.sr.ht/~vejnar/ReadKnead/lib/fastq"
 )
 
 // Trim by search
-func TrimSearch(r *fastq.Record, adaptors [][]byte, minAdaptor int, minScore float32, trimSide int, applyTrimSeq bool, verboseLevel int) (TrimType, int, float32, []byte) {
-	var trimIdx, trimStart, trimEnd, nMatch, nMismatch int
+func TrimSearch(r *fastq.Record, adaptors [][]byte, minAdaptor int, minScore float32, trimSide int, nMode bio.NMode, action Action, verboseLevel int) (TrimType, int, float32, []byte) {
+	var trimIdx, trimStart, trimEnd int
 	var trimType TrimType
-	var trimScore, tmpScore float32
+	var trimScore, tmpScore, nMatch, nMismatch float32
 	var trimSeq []byte
 	trimEnd = len(r.Seq)
 	// Find matching adaptor
@@ -27,13 +28,11 @@ func TrimSearch(r *fastq.Record, adaptors [][]byte, minAdaptor int, minScore flo
 				nMismatch = 0
 				subseq := r.Seq[:i]
 				for j, k := len(subseq)-1, len(adaptor)-1; j >= 0 && k >= 0; j, k = j-1, k-1 {
-					if subseq[j] == adaptor[k] {
-						nMatch++
-					} else {
-						nMismatch++
-					}
+					s := bio.CompareIUPAC(subseq[j], adaptor[k], nMode)
+					nMatch += s
+					nMismatch += 1 - s
 				}
-				tmpScore = float32(nMatch) / float32(nMatch+nMismatch)
+				tmpScore = nMatch / (nMatch + nMismatch)
 				if tmpScore > trimScore {
 					if nMismatch == 0 {
 						trimType = TrimExactType
@@ -56,13 +55,11 @@ func TrimSearch(r *fastq.Record, adaptors [][]byte, minAdaptor int, minScore flo
 				nMismatch = 0
 				subseq := r.Seq[i:]
 				for j := 0; j < len(subseq) && j < len(adaptor); j++ {
-					if subseq[j] == adaptor[j] {
-						nMatch++
-					} else {
-						nMismatch++
-					}
+					s := bio.CompareIUPAC(subseq[j], adaptor[j], nMode)
+					nMatch += s
+					nMismatch += 1 - s
 				}
-				tmpScore = float32(nMatch) / float32(nMatch+nMismatch)
+				tmpScore = nMatch / (nMatch + nMismatch)
 				if tmpScore > trimScore {
 					if nMismatch == 0 {
 						trimType = TrimExactType
@@ -82,9 +79,8 @@ func TrimSearch(r *fastq.Record, adaptors [][]byte, minAdaptor int, minScore flo
 		}
 	}
 	// Apply trimming
-	if applyTrimSeq && trimType != NoTrimType {
-		r.Seq = r.Seq[trimStart:trimEnd]
-		r.Qual = r.Qual[trimStart:trimEnd]
+	if trimType != NoTrimType {
+		action.Apply(r, trimStart, trimEnd)
 	}
 	return trimType, trimIdx, trimScore, trimSeq
 }